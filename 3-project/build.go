@@ -0,0 +1,146 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// buildFlags are the flags of the commands that build
+type buildFlags struct {
+	flags        *flag.FlagSet
+	path         *string
+	events       *string
+	eventsFile   *string
+	jobs         *int
+	showProgress *bool
+	wait         *bool
+	compare      *string
+	s3           *s3Flags
+	history      *string
+}
+
+func addBuildFlags(flags *flag.FlagSet) *buildFlags {
+	return &buildFlags{
+		flags:        flags,
+		path:         flags.String("d", "", "Files location, (current directory by default)"),
+		events:       flags.String("events", "", "Write build events in the given format (jsonl)"),
+		eventsFile:   flags.String("events-file", "", "Where events are written to (stdout by default)"),
+		jobs:         flags.Int("j", 0, "Use a ready queue served by this many workers instead of a worker per file"),
+		showProgress: flags.Bool("progress", true, "Show the build progress if stdout is a terminal"),
+		compare:      flags.String("compare", "strict", "How targets are compared with their deps: strict, tolerant=<window> (e.g. tolerant=2s) or granular"),
+		wait:         flags.Bool("wait", false, "Wait for the build running on the same files location, instead of failing"),
+		s3:           addS3Flags(flags),
+		history:      addHistoryFlag(flags),
+	}
+}
+
+// lockPoll is how often a locked files location
+// is checked while waiting for its build
+const lockPoll = 200 * time.Millisecond
+
+// openFileScan returns the scan of the files location,
+// waiting for the build holding its lock, if asked to.
+// Otherwise it tells how to wait for it.
+func openFileScan(path string, wait bool) (*utils.FileScan, error) {
+	for waiting := false; ; waiting = true {
+		scan, err := utils.NewFileScan(path)
+		if _, ok := err.(*utils.Locked); !ok {
+			return scan, err
+		}
+		if !wait {
+			return nil, fmt.Errorf("%w: another build is running, use -wait to wait for it", err)
+		}
+		if !waiting {
+			log.Printf("%v. Waiting for it...", err)
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+// scan returns where the files are: the bucket
+// if -s3 is given, otherwise the files location
+func (bf *buildFlags) scan() (utils.Scan, error) {
+	if bf.s3.set() {
+		return bf.s3.scan()
+	}
+	return openFileScan(*bf.path, *bf.wait)
+}
+
+// build builds the goals of the dependency file
+// (its default ones if none is given), showing
+// its progress and events, and keeps its history.
+// The scan, and its lock, is taken once nothing
+// else can fail, since exiting wouldn't release it.
+func (bf *buildFlags) build(dFile *parser.DepFile, goals ...string) {
+	if len(goals) == 0 {
+		goals = dFile.Goals()
+	}
+
+	if err := bf.s3.check(bf.flags); err != nil {
+		log.Fatal(err.Error())
+	}
+	compare, err := builder.ParseCompare(*bf.compare)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	hist := loadHistory(bf.s3.historyPath(*bf.history, *bf.path))
+	sinks := []sink{hist.record, warnings(os.Stderr)}
+	var out io.Writer = os.Stdout
+	if *bf.events != "" {
+		w, err := eventsOutput(*bf.events, *bf.eventsFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		defer w.Close()
+		if w == os.Stdout {
+			out = os.Stderr // Keeps the stream clean
+		}
+		sinks = append(sinks, jsonLines(w))
+	}
+	var p *progress
+	if *bf.showProgress && out == os.Stdout && isTerminal(os.Stdout) {
+		nodes, err := builder.NewGraph(dFile).Needed(goals)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		p = newProgress(os.Stdout, nodes, hist.estimates())
+		sinks = append(sinks, p.show)
+	}
+
+	scan, err := bf.scan()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if c, ok := scan.(io.Closer); ok {
+		defer func() {
+			if err := c.Close(); err != nil {
+				log.Printf("Couldn't release the files location: %v", err)
+			}
+		}()
+	}
+	if p != nil {
+		log.SetOutput(io.Discard) // Would mess up the display
+	}
+
+	evCh := make(chan *builder.Event, 64)
+	eventsDone := consume(evCh, sinks...)
+
+	ch := builder.MakeController(
+		dFile, scan,
+		builder.WithEvents(evCh), builder.WithWorkers(*bf.jobs),
+		builder.WithDurations(hist.estimates()), builder.WithGoals(goals...),
+		builder.WithCompare(compare),
+	)
+	oneShot(ch, eventsDone, out)
+	if err := hist.save(); err != nil {
+		log.Printf("Couldn't save the build history: %v", err)
+	}
+}
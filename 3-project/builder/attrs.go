@@ -0,0 +1,143 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// InvalidAttr is an attribute of a rule
+// that the builder doesn't understand
+type InvalidAttr struct {
+	target string
+	attr   *parser.Attr
+	err    error
+}
+
+func (e *InvalidAttr) Error() string {
+	return fmt.Sprintf("invalid attribute %q of %q: %v", e.attr, e.target, e.err)
+}
+
+// TimedOut is the error of a build
+// that took longer than its timeout
+type TimedOut struct {
+	target  string
+	timeout time.Duration
+}
+
+func (e *TimedOut) Error() string {
+	return fmt.Sprintf("build of %q timed out after %v", e.target, e.timeout)
+}
+
+// configure applies the attributes of the file's rule:
+// timeout (of each attempt), retries, pool (one of the
+// given ones) and phony, which buildGraph already took
+// into account
+func (f *fileInfo) configure(pools map[string]chan struct{}) error {
+	seen := make(map[string]bool)
+	for _, a := range f.attrs {
+		invalid := func(err error) error {
+			return &InvalidAttr{target: f.filename, attr: a, err: err}
+		}
+		if seen[a.Key] {
+			return invalid(errors.New("set more than once"))
+		}
+		seen[a.Key] = true
+
+		switch a.Key {
+		case "timeout":
+			d, err := time.ParseDuration(a.Value)
+			if err != nil {
+				return invalid(err)
+			}
+			if d <= 0 {
+				return invalid(errors.New("must be positive"))
+			}
+			f.timeout = d
+		case "retries":
+			n, err := strconv.Atoi(a.Value)
+			if err != nil {
+				return invalid(err)
+			}
+			f.retries = n
+		case "pool":
+			pool, ok := pools[a.Value]
+			if !ok {
+				return invalid(errors.New("unknown pool"))
+			}
+			f.pool = pool
+		case "phony":
+			if a.Value != "" {
+				return invalid(errors.New("takes no value"))
+			}
+		default:
+			return invalid(errors.New("unknown attribute"))
+		}
+	}
+	return nil
+}
+
+// configure makes the declared pools and applies
+// the attributes of every target
+func (dG *depGraph) configure(declared []*parser.Pool) error {
+	pools, err := makePools(declared)
+	if err != nil {
+		return err
+	}
+	for _, info := range dG.targets {
+		if err := info.configure(pools); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt builds the file once, holding a slot
+// of its pool, and gives up after the timeout,
+// if there's one. Since a Scan can't be stopped,
+// the build may keep going (and keep its slot)
+// in the background: done is closed once it
+// has returned and given its slot back.
+func (f *fileInfo) attempt() (time.Time, <-chan struct{}, error) {
+	done := make(chan struct{})
+	f.enterPool()
+	if f.timeout <= 0 {
+		defer close(done)
+		defer f.leavePool()
+		t, err := f.Build(f.filename)
+		return t, done, err
+	}
+
+	resCh := make(chan *result, 1) // Never blocks the build
+	go func() {
+		defer close(done)
+		defer f.leavePool()
+		t, err := f.Build(f.filename)
+		resCh <- &result{info: f, t: t, err: err}
+	}()
+	select {
+	case r := <-resCh:
+		return r.t, done, r.err
+	case <-time.After(f.timeout):
+		return time.Time{}, done, &TimedOut{target: f.filename, timeout: f.timeout}
+	}
+}
+
+// attempts builds the file, retrying on failure.
+// A retry waits for the previous attempt to return,
+// so the same file is never built twice at once.
+func (f *fileInfo) attempts() (time.Time, error) {
+	t, done, err := f.attempt()
+	for try := 1; err != nil && try <= f.retries; try++ {
+		<-done
+		log.Printf(
+			"Retrying %q (%d/%d) after error: %v",
+			f.filename, try, f.retries, err,
+		)
+		t, done, err = f.attempt()
+	}
+	return t, err
+}
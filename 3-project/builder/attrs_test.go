@@ -0,0 +1,197 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyScan fails the builds of a file as many times
+// as tokens in its channel, and takes delay to build
+type flakyScan struct {
+	fails map[string]chan struct{}
+	delay map[string]time.Duration
+	built chan string
+	running chan struct{} // Optional, a slot for each build
+	overlapped chan string // Optional, receives builds that found no slot
+}
+
+func (s *flakyScan) Status(filename string) (time.Time, error) {
+	return time.Time{}, missing
+}
+
+func (s *flakyScan) Build(filename string) (time.Time, error) {
+	if s.running != nil {
+		select {
+		case s.running <- struct{}{}:
+			defer func() { <-s.running }()
+		default:
+			s.overlapped <- filename
+		}
+	}
+	time.Sleep(s.delay[filename])
+	select {
+	case <-s.fails[filename]:
+		return time.Time{}, &buildError{filename: filename}
+	default:
+	}
+	s.built <- filename
+	return time.Now(), nil
+}
+
+func failing(n int) chan struct{} {
+	ch := make(chan struct{}, n)
+	for ; n > 0; n-- {
+		ch <- struct{}{}
+	}
+	return ch
+}
+
+func TestAttrsRetries(t *testing.T) {
+	for name, opts := range schedulers {
+		t.Run(name, func(t *testing.T) {
+			fileScan := &flakyScan{
+				fails: map[string]chan struct{}{
+					"r":  failing(2),
+					"d1": failing(1),
+				},
+				built: make(chan string, 3),
+			}
+
+			s := `
+r  [retries=2] <- d1 d2;
+d1 [retries=1] <- ;
+`
+			dFile, _ := parser.Parse(s)
+
+			msg := <-MakeController(dFile, fileScan, opts...)
+			if msg.Type != BuildSuccess {
+				t.Fatalf("Got an unnexpected error: %v", msg.Err)
+			}
+			if built := drain(fileScan.built); len(built) != 3 {
+				t.Errorf("Expecting r, d1 and d2 to be built. got=%v", built)
+			}
+
+			// Not enough retries
+			fileScan.fails["d1"] = failing(2)
+			msg = <-MakeController(dFile, fileScan, opts...)
+			if msg.Type != BuildError {
+				t.Fatal("Expecting message of type BuildError")
+			}
+			if err, ok := msg.Err.(*buildError); !ok || err.filename != "d1" {
+				t.Errorf("Expecting build error from d1. got=%v", msg.Err)
+			}
+		})
+	}
+}
+
+func TestAttrsTimeout(t *testing.T) {
+	for name, opts := range schedulers {
+		t.Run(name, func(t *testing.T) {
+			fileScan := &flakyScan{
+				delay: map[string]time.Duration{"d1": time.Second},
+				built: make(chan string, 3),
+			}
+
+			s := `
+r  <- d1;
+d1 [timeout=10ms] <- ;
+`
+			dFile, _ := parser.Parse(s)
+
+			msg := <-MakeController(dFile, fileScan, opts...)
+			if msg.Type != BuildError {
+				t.Fatal("Expecting message of type BuildError")
+			}
+			var err *TimedOut
+			if !errors.As(msg.Err, &err) || err.target != "d1" {
+				t.Errorf("Expecting d1 to time out. got=%v", msg.Err)
+			}
+		})
+	}
+}
+
+func TestAttrsTimeoutRetries(t *testing.T) {
+	for name, opts := range schedulers {
+		t.Run(name, func(t *testing.T) {
+			fileScan := &flakyScan{
+				delay: map[string]time.Duration{"d1": 30 * time.Millisecond},
+				built: make(chan string, 3),
+				running: make(chan struct{}, 1),
+				overlapped: make(chan string, 3),
+			}
+
+			s := `
+r  <- d1;
+d1 [timeout=10ms, retries=2] <- ;
+`
+			dFile, _ := parser.Parse(s)
+
+			msg := <-MakeController(dFile, fileScan, opts...)
+			if msg.Type != BuildError {
+				t.Fatal("Expecting message of type BuildError")
+			}
+			if n := len(fileScan.overlapped); n != 0 {
+				t.Errorf("Retries shouldn't start before the timed out build returns. overlapped=%d", n)
+			}
+		})
+	}
+}
+
+func TestAttrsPhony(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			"test": {time: convertTime("10")},
+			"r":    {time: convertTime("10")},
+			"d1":   {time: convertTime("04")},
+		},
+		checked: make(chan string, 3),
+		built:   make(chan string, 3),
+	}
+
+	s := `
+test [phony] <- r;
+r <- d1;
+`
+	dFile, _ := parser.Parse(s)
+
+	msg := <-MakeController(dFile, fileScan)
+	if msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+	if n := drain(fileScan.checked)["test"]; n != 0 {
+		t.Errorf("Phony target was checked %d times", n)
+	}
+	if built := drain(fileScan.built); len(built) != 1 || built["test"] != 1 {
+		t.Errorf("Expecting only test to be built. got=%v", built)
+	}
+}
+
+func TestInvalidAttrs(t *testing.T) {
+	for _, s := range []string{
+		"r [timeout=soon] <- d;",
+		"r [timeout=0s] <- d;",
+		"r [retries=many] <- d;",
+		"r [retries=2, retries=3] <- d;",
+		"r [phony=yes] <- d;",
+		"r [color=blue] <- d;",
+	} {
+		dFile, err := parser.Parse(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		fileScan := &fakeScan{built: make(chan string, 2)}
+
+		msg := <-MakeController(dFile, fileScan)
+		if msg.Type != BuildError {
+			t.Fatalf("%s: expecting message of type BuildError", s)
+		}
+		if _, ok := msg.Err.(*InvalidAttr); !ok {
+			t.Errorf("%s: Err isn't of type InvalidAttr: got=%v", s, msg.Err)
+		}
+		if built := drain(fileScan.built); len(built) != 0 {
+			t.Errorf("%s: nothing should be built. got=%v", s, built)
+		}
+	}
+}
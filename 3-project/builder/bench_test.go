@@ -0,0 +1,127 @@
+package builder
+
+import (
+	"cpl_go_proj22/graphgen"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// sizes of the generated graphs, in files
+var sizes = []int{1000, 10000, 100000}
+
+// genConfig is the shape of the benchmarked graphs
+func genConfig(nodes int, existing, stale float64) graphgen.Config {
+	return graphgen.Config{
+		Nodes:     nodes,
+		Depth:     10,
+		MaxFanIn:  5,
+		MaxFanOut: 10,
+		Existing:  existing,
+		Stale:     stale,
+		Seed:      1,
+	}
+}
+
+// genScan returns a scan with the files of g
+func genScan(g *graphgen.Graph) *fakeScan {
+	fileScan := &fakeScan{files: make(map[string]*fakeFileInfo, len(g.Nodes))}
+	for _, f := range g.Nodes {
+		info := &fakeFileInfo{}
+		if t, ok := g.Times[f]; ok {
+			info.time = &t
+		}
+		fileScan.files[f] = info
+	}
+	return fileScan
+}
+
+// disableLog silences the workers during benchmarks
+func disableLog(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func BenchmarkBuildGraph(b *testing.B) {
+	for _, n := range sizes {
+		g := graphgen.Generate(genConfig(n, 0, 0))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				buildGraph(g.File)
+			}
+		})
+	}
+}
+
+// BenchmarkSpawn measures MakeController until it
+// returns, i.e. building the graph and spawning the
+// workers, which then check up to date files
+func BenchmarkSpawn(b *testing.B) {
+	disableLog(b)
+	for _, n := range sizes {
+		g := graphgen.Generate(genConfig(n, 1, 0))
+		fileScan := genScan(g)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tunnel := MakeController(g.File, fileScan)
+				b.StopTimer()
+				<-tunnel
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func benchmarkBuild(b *testing.B, existing, stale float64, opts ...Option) {
+	disableLog(b)
+	for _, n := range sizes {
+		g := graphgen.Generate(genConfig(n, existing, stale))
+		fileScan := genScan(g)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				msg := <-MakeController(g.File, fileScan, opts...)
+				if msg.Type != BuildSuccess {
+					b.Fatalf("Got an unnexpected error: %v", msg.Err)
+				}
+			}
+		})
+	}
+}
+
+// Nothing exists, everything is built
+func BenchmarkOneShot(b *testing.B) { benchmarkBuild(b, 0, 0) }
+
+// Everything is up to date, nothing is built
+func BenchmarkNoOp(b *testing.B) { benchmarkBuild(b, 1, 0) }
+
+// Half of the files exist, a fifth of them are stale
+func BenchmarkIncremental(b *testing.B) { benchmarkBuild(b, 0.5, 0.2) }
+
+func BenchmarkQueueOneShot(b *testing.B) { benchmarkBuild(b, 0, 0, WithWorkers(cpus)) }
+
+func BenchmarkQueueNoOp(b *testing.B) { benchmarkBuild(b, 1, 0, WithWorkers(cpus)) }
+
+func BenchmarkQueueIncremental(b *testing.B) { benchmarkBuild(b, 0.5, 0.2, WithWorkers(cpus)) }
+
+// noOpScan makes sure that an up to date graph isn't built
+type noOpScan struct {
+	*fakeScan
+}
+
+func (s noOpScan) Build(filename string) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("%q was built", filename)
+}
+
+func TestGeneratedNoOp(t *testing.T) {
+	g := graphgen.Generate(genConfig(2000, 1, 0))
+	fileScan := noOpScan{genScan(g)}
+
+	for _, opts := range [][]Option{nil, {WithWorkers(4)}} {
+		if msg := <-MakeController(g.File, fileScan, opts...); msg.Type != BuildSuccess {
+			t.Errorf("Up to date graph was built: %v", msg.Err)
+		}
+	}
+}
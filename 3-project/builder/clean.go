@@ -0,0 +1,82 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"fmt"
+	"log"
+	"sort"
+)
+
+type UnknownTarget struct {
+	target string
+}
+
+func (e *UnknownTarget) Error() string {
+	return fmt.Sprintf("target %q isn't in the dependency file", e.target)
+}
+
+// subGraph returns the nodes reachable from
+// target, the target itself included
+func (dG *depGraph) subGraph(target string) (map[string]*fileInfo, error) {
+	info, ok := dG.nodes[target]
+	if !ok {
+		return nil, &UnknownTarget{target: target}
+	}
+
+	nodes := make(map[string]*fileInfo)
+	stack := []*fileInfo{info}
+	for len(stack) > 0 {
+		info, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		if _, ok := nodes[info.filename]; ok {
+			continue
+		}
+		nodes[info.filename] = info
+		for _, dep := range info.deps {
+			stack = append(stack, dG.nodes[dep])
+		}
+	}
+	return nodes, nil
+}
+
+// Clean removes the built objects of every target
+// (leafs are never touched) or, if target isn't
+// empty, only the ones of its sub-graph. Phony and
+// missing targets are skipped. Returns the removed
+// objects or, on a dry run, the ones that would be.
+func Clean(
+	file *parser.DepFile,
+	fileScan utils.CleanScan,
+	target string,
+	dryRun bool,
+) ([]string, error) {
+	dG := buildGraph(file)
+
+	nodes := dG.nodes
+	if target != "" {
+		var err error
+		if nodes, err = dG.subGraph(target); err != nil {
+			return nil, err
+		}
+	}
+
+	var removed []string
+	for _, info := range dG.targets {
+		if _, ok := nodes[info.filename]; !ok || info.phony {
+			continue
+		}
+		if _, err := fileScan.Status(info.filename); err != nil {
+			continue // Nothing to remove
+		}
+		if !dryRun {
+			if err := fileScan.Remove(info.filename); err != nil {
+				return removed, err
+			}
+			log.Printf("%q removed", info.filename)
+		}
+		removed = append(removed, info.filename)
+	}
+	sort.Strings(removed)
+
+	return removed, nil
+}
@@ -0,0 +1,82 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"testing"
+)
+
+func (s *fakeScan) Remove(filename string) error {
+	s.files[filename].time = nil
+	return nil
+}
+
+func TestClean(t *testing.T) {
+	s := `
+phony test <- r;
+r  <- d1 d2;
+d1 <- d3;
+d2 <- d3 d4;
+`
+
+	newScan := func() *fakeScan {
+		return &fakeScan{
+			files: map[string]*fakeFileInfo{
+				"test": {time: convertTime("09")},
+				   "r": {time: convertTime("08")},
+				  "d1": {time: convertTime("07")},
+				  "d2": {},
+				  "d3": {time: convertTime("01")},
+				  "d4": {time: convertTime("02")},
+			},
+		}
+	}
+
+	dFile, _ := parser.Parse(s)
+
+	checkRemoved := func(removed []string, expect ...string) {
+		if len(removed) != len(expect) {
+			t.Errorf("Wrong removed files. got=%v, expect=%v", removed, expect)
+			return
+		}
+		for i := range expect {
+			if removed[i] != expect[i] {
+				t.Errorf("Wrong removed files. got=%v, expect=%v", removed, expect)
+				return
+			}
+		}
+	}
+
+	fileScan := newScan()
+	removed, err := Clean(dFile, fileScan, "", true)
+	if err != nil {
+		t.Fatalf("Got an unnexpected error: %v", err)
+	}
+	checkRemoved(removed, "d1", "r")
+	if fileScan.files["r"].time == nil {
+		t.Error("Dry run shouldn't remove files")
+	}
+
+	removed, _ = Clean(dFile, fileScan, "", false)
+	checkRemoved(removed, "d1", "r")
+	for _, f := range []string{"r", "d1"} {
+		if fileScan.files[f].time != nil {
+			t.Errorf("Target %q wasn't removed", f)
+		}
+	}
+	for _, f := range []string{"test", "d3", "d4"} {
+		if fileScan.files[f].time == nil {
+			t.Errorf("File %q shouldn't be removed", f)
+		}
+	}
+
+	fileScan = newScan()
+	removed, _ = Clean(dFile, fileScan, "d1", false)
+	checkRemoved(removed, "d1")
+	if fileScan.files["r"].time == nil {
+		t.Error("Target r is outside of the d1 sub-graph")
+	}
+
+	if _, err = Clean(dFile, fileScan, "d5", false); err == nil {
+		t.Error("Expecting an error cleaning an unknown target")
+	}
+}
@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Compare tells if a target whose modification time
+// is target is up to date with a dep of time dep
+type Compare func(target, dep time.Time) bool
+
+// Strict only takes targets that are newer than the
+// dep as up to date, so a dep built in the same tick
+// of a coarse file system triggers a rebuild
+func Strict(target, dep time.Time) bool {
+	return target.After(dep)
+}
+
+// Tolerant takes targets as up to date unless the dep
+// is newer by the window or more, e.g. 2s for FAT. Ties
+// are up to date. Tolerant(0) is Strict, but for ties.
+func Tolerant(window time.Duration) Compare {
+	return func(target, dep time.Time) bool {
+		return dep.Before(target.Add(window)) || dep.Equal(target)
+	}
+}
+
+// Granular compares both times at the resolution of
+// the coarser one, guessed from its trailing zeros, so
+// a file system with seconds doesn't make a target look
+// older than a dep from one with nanoseconds. Ties at
+// that resolution are up to date.
+func Granular(target, dep time.Time) bool {
+	r := resolution(target)
+	if d := resolution(dep); d > r {
+		r = d
+	}
+	return !target.Truncate(r).Before(dep.Truncate(r))
+}
+
+// resolution guesses the precision of a time
+// by the trailing zeros of its nanoseconds
+func resolution(t time.Time) time.Duration {
+	ns := t.Nanosecond()
+	switch {
+	case ns == 0:
+		return time.Second
+	case ns%1e6 == 0:
+		return time.Millisecond
+	case ns%1e3 == 0:
+		return time.Microsecond
+	}
+	return time.Nanosecond
+}
+
+// ParseCompare reads "strict", "granular" or
+// "tolerant=<window>", e.g. "tolerant=2s"
+func ParseCompare(s string) (Compare, error) {
+	name, window, hasWindow := strings.Cut(s, "=")
+	switch {
+	case name == "strict" && !hasWindow:
+		return Strict, nil
+	case name == "granular" && !hasWindow:
+		return Granular, nil
+	case name == "tolerant" && hasWindow:
+		d, err := time.ParseDuration(window)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid window of %q", s)
+		}
+		return Tolerant(d), nil
+	}
+	return nil, fmt.Errorf("unknown comparison %q, expecting strict, tolerant=<window> or granular", s)
+}
+
+// futureSlack is how far in the future a modification
+// time can be before it's reported, e.g. because of
+// the clock of a network file system
+const futureSlack = time.Second
+
+// checkFuture reports the file if its modification
+// time is in the future: it would be up to date until
+// then, however new its deps are. The present is
+// the one of the clock of the options.
+func (f *fileInfo) checkFuture(t time.Time) {
+	if t.After(f.opts.now().Add(futureSlack)) {
+		log.Printf("%q was modified in the future: %v", f.filename, t)
+		f.notify(&Event{Type: FutureModTime, ModTime: &t})
+	}
+}
@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	base := time.Date(2001, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return base.Add(d) }
+
+	cases := []struct {
+		name        string
+		target, dep time.Time
+		strict      bool
+		tolerant    bool // 2s window
+		granular    bool
+	}{
+		{"newer target", at(time.Minute), at(0), true, true, true},
+		{"same tick", at(0), at(0), false, true, true},
+		{"newer dep", at(0), at(time.Minute), false, false, false},
+		{"dep within window", at(0), at(time.Second), false, true, false},
+		{"dep at the window", at(0), at(2 * time.Second), false, false, false},
+		{"coarse target", at(0), at(300 * time.Millisecond), false, true, true},
+		{"coarse target, newer dep", at(0), at(1300 * time.Millisecond), false, true, false},
+		{"coarse dep", at(300 * time.Millisecond), at(0), true, true, true},
+		{"both fine", at(300), at(301), false, true, false},
+		{"millis and nanos", at(5 * time.Millisecond), at(5*time.Millisecond + 7), false, true, true},
+	}
+	tolerant := Tolerant(2 * time.Second)
+	for _, c := range cases {
+		if got := Strict(c.target, c.dep); got != c.strict {
+			t.Errorf("%s: Strict expected=%v, got=%v", c.name, c.strict, got)
+		}
+		if got := tolerant(c.target, c.dep); got != c.tolerant {
+			t.Errorf("%s: Tolerant expected=%v, got=%v", c.name, c.tolerant, got)
+		}
+		if got := Granular(c.target, c.dep); got != c.granular {
+			t.Errorf("%s: Granular expected=%v, got=%v", c.name, c.granular, got)
+		}
+	}
+}
+
+func TestParseCompare(t *testing.T) {
+	base := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	for s, upToDate := range map[string]bool{
+		"strict":        false,
+		"granular":      true,
+		"tolerant=2s":   true,
+		"tolerant=10ms": false,
+	} {
+		c, err := ParseCompare(s)
+		if err != nil {
+			t.Errorf("Couldn't parse %q: %v", s, err)
+			continue
+		}
+		if got := c(base, base.Add(300*time.Millisecond)); got != upToDate {
+			t.Errorf("Wrong comparison of %q. expected=%v, got=%v", s, upToDate, got)
+		}
+	}
+	for _, s := range []string{"", "loose", "tolerant", "tolerant=-1s", "strict=1s"} {
+		if _, err := ParseCompare(s); err == nil {
+			t.Errorf("Expecting an error parsing %q", s)
+		}
+	}
+}
+
+func TestBuildCompare(t *testing.T) {
+	// Built in the same tick
+	start := *convertTime("01")
+	s := "r <- d1;"
+	dFile, _ := parser.Parse(s)
+
+	for _, sched := range [][]Option{nil, {WithWorkers(2)}} {
+		for _, c := range []struct {
+			compare Compare
+			builds  int
+		}{
+			{Strict, 1},
+			{Tolerant(time.Second), 0},
+		} {
+			scan := utils.NewMemScan(start, time.Minute)
+			scan.Set("r", start)
+			scan.Set("d1", start)
+			opts := append([]Option{WithCompare(c.compare)}, sched...)
+			if msg := <-MakeController(dFile, scan, opts...); msg.Type != BuildSuccess {
+				t.Fatalf("Got an unnexpected error: %v", msg.Err)
+			}
+			if n := scan.Builds("r"); n != c.builds {
+				t.Errorf("Expecting r to be built %d times. got=%d", c.builds, n)
+			}
+			scan.Close()
+		}
+	}
+}
+
+func TestFutureModTime(t *testing.T) {
+	s := "r <- d1 d2;"
+	dFile, _ := parser.Parse(s)
+
+	for _, sched := range [][]Option{nil, {WithWorkers(2)}} {
+		now := time.Now()
+		scan := utils.NewMemScan(now, time.Millisecond)
+		scan.Set("r", now.Add(time.Hour))
+		scan.Set("d1", now.Add(-time.Hour))
+		scan.Set("d2", now.Add(time.Minute))
+
+		evCh := make(chan *Event, 16)
+		ch := MakeController(dFile, scan, append(sched, WithEvents(evCh))...)
+		future := make(map[string]bool)
+		for ev := range evCh {
+			if ev.Type == FutureModTime {
+				future[ev.Target] = true
+			}
+		}
+		<-ch
+		scan.Close()
+
+		if len(future) != 2 || !future["r"] || !future["d2"] {
+			t.Errorf("Expecting r and d2 to be in the future. got=%v", future)
+		}
+	}
+}
+
+func TestFutureModTimeClock(t *testing.T) {
+	s := "r <- d1 d2;"
+	dFile, _ := parser.Parse(s)
+
+	// Far from the real time, but not in the
+	// future of the clock of the scan
+	for _, sched := range [][]Option{nil, {WithWorkers(2)}} {
+		start := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+		scan := utils.NewMemScan(start, time.Millisecond)
+		scan.Set("r", start.Add(-time.Minute))
+		scan.Set("d1", start.Add(-time.Hour))
+		scan.Set("d2", start.Add(time.Minute))
+
+		evCh := make(chan *Event, 16)
+		opts := append(sched, WithEvents(evCh), WithClock(scan.Now))
+		ch := MakeController(dFile, scan, opts...)
+		var future []string
+		for ev := range evCh {
+			if ev.Type == FutureModTime {
+				future = append(future, ev.Target)
+			}
+		}
+		<-ch
+		scan.Close()
+
+		// Only d2 is ahead of the virtual clock
+		if len(future) != 1 || future[0] != "d2" {
+			t.Errorf("Expecting only d2 to be in the future. got=%v", future)
+		}
+	}
+}
@@ -26,20 +26,47 @@ type Msg struct {
 type fileInfo struct {
 	// Set while building the graph
 	filename string
+	phony bool // Never stat'ed, always built
 	dependencies int
+	deps []string // Including order-only ones
 	dependants []string
+	orderDependants []string // Only wait for this file
 	nodes map[string]*fileInfo
+	attrs []*parser.Attr
+
+	// Set by the attributes
+	timeout time.Duration // Of each attempt, if positive
+	retries int
+	pool chan struct{} // Semaphore shared by its pool
 
 	// Set when spawning workers
 	utils.Scan	
-	timesCh chan time.Time
+	opts *options
+	timesCh chan depTime
 	panicCh chan struct{} // When some error happens
 	errorCh chan *Msg // Communicate with the error controller
+
+	// Only touched by the queue coordinator
+	remaining int // Deps that aren't ready yet
+	newest depTime // Most recent time of its deps
+	priority time.Duration // Longest path to the root
+}
+
+// depTime is the time of a dependency
+type depTime struct {
+	filename string
+	t time.Time
 }
 
 func (f *fileInfo) propagate(t time.Time) {
 	for _, dep := range f.dependants {
-		f.nodes[dep].timesCh <-t	
+		f.nodes[dep].timesCh <-depTime{f.filename, t}	
+	}
+	// Order-only dependants just need to know
+	// that the file is there, so they receive
+	// a zero time that never triggers a rebuild
+	for _, dep := range f.orderDependants {
+		f.nodes[dep].timesCh <-depTime{filename: f.filename}
 	}
 	log.Printf(
 		"%q propagated build time %q to %v", 
@@ -47,22 +74,45 @@ func (f *fileInfo) propagate(t time.Time) {
 	)
 }
 
-// build tries to build the file 
-// and sends the build time to its
-// dependants.
-func (f *fileInfo) build() {
-	t, err := f.Build(f.filename)
+// tryBuild builds the file, notifying why
+// and how it went, and returns its build time
+func (f *fileInfo) tryBuild(why *Event) (time.Time, error) {
+	why.Type = TargetStarted
+	f.notify(why)
+	start := time.Now()
+	t, err := f.attempts()
+	d := time.Since(start)
 	if err != nil {
 		log.Printf(
 			"Error while trying to build %q: %v", 
 			f.filename, err,
 		)
+		f.notify(&Event{Type: TargetFailed, Duration: d, Err: err.Error()})
+		return t, err
+	}
+	f.notify(&Event{Type: TargetBuilt, ModTime: &t, Duration: d})
+	return t, nil
+}
+
+// build tries to build the file 
+// and sends the build time to its
+// dependants.
+func (f *fileInfo) build(why *Event) {
+	t, err := f.tryBuild(why)
+	if err != nil {
 		f.errorCh <-&Msg{Type: BuildError, Err: err}
 		return
 	}
 	f.propagate(t)
 }
 
+// skip sends the time of an up to
+// date file to its dependants
+func (f *fileInfo) skip(t time.Time) {
+	f.notify(&Event{Type: TargetSkipped, ModTime: &t})
+	f.propagate(t)
+}
+
 type depGraph struct {
 	leafs map[string]*fileInfo // Build starter workers
 	nodes map[string]*fileInfo // Searching and testing purposes
@@ -94,34 +144,75 @@ func buildGraph(file *parser.DepFile) *depGraph {
 		dG.targets = append(dG.targets, info)
 	}
 
+	// Unknown deps are leafs until
+	// we find a rule for them
+	getDep := func(dep string) *fileInfo {
+		info, ok := dG.nodes[dep]
+		if !ok {
+			info = insertNode(dep)
+			dG.leafs[dep] = info 
+		}
+		return info
+	}
+
 	for _, rule := range file.Rules {
 		target := rule.Object
 
 		for _, dep := range rule.Deps {
-			info, ok := dG.nodes[dep]
-			if !ok {
-				info = insertNode(dep)
-				dG.leafs[dep] = info 
-			}
+			info := getDep(dep)
 			info.dependants = append(info.dependants, target)
 		}
+		for _, dep := range rule.OrderOnly {
+			info := getDep(dep)
+			info.orderDependants = append(info.orderDependants, target)
+		}
 
 		delete(dG.leafs, target) // It means that's no more a leaf
 		info, ok := dG.nodes[target]
 		if !ok {
 			info = insertNode(target)
 		}
-		info.dependencies = len(rule.Deps)
+		info.phony = rule.IsPhony()
+		info.attrs = rule.Attrs
+		info.deps = make([]string, 0, len(rule.Deps) + len(rule.OrderOnly))
+		info.deps = append(append(info.deps, rule.Deps...), rule.OrderOnly...)
+		info.dependencies = len(info.deps)
 		insertTarget(info)
 	}
 
 	return dG
 }
 
+// waitDeps receives the times of the given number
+// of dependencies. Returns false if some other
+// worker has failed in the meantime.
+func (f *fileInfo) waitDeps(deps int) bool {
+	for ; deps > 0; deps-- {
+		select {
+		case <-f.panicCh:
+			return false
+		case <-f.timesCh:
+		}
+	}
+	return true
+}
+
 func targetWorker(info *fileInfo, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	deps := info.dependencies
+
+	if info.phony {
+		log.Printf(
+			"%q is phony. Proceeds to build after wait", 
+			info.filename,
+		)
+		// Never checked, so it's always built
+		if info.waitDeps(deps) {
+			info.build(&Event{Reason: ReasonPhony})
+		}
+		return
+	}
 	
 	sTime, err := info.Status(info.filename)
 	if err != nil {
@@ -130,16 +221,12 @@ func targetWorker(info *fileInfo, wg *sync.WaitGroup) {
 			info.filename,
 		)
 		// Only needs to wait for its dependencies
-		for ; deps > 0; deps-- {
-			select {
-			case <-info.panicCh:
-				return
-			case <-info.timesCh:
-			}
-		}	
-		info.build()
+		if info.waitDeps(deps) {
+			info.build(&Event{Reason: ReasonMissing})
+		}
 		return
 	}
+	info.checkFuture(sTime)
 
 	// Waits until some of its dependencies
 	// has an update time greater than the target
@@ -147,8 +234,8 @@ func targetWorker(info *fileInfo, wg *sync.WaitGroup) {
 		select {
 		case <-info.panicCh:
 			return
-		case t := <-info.timesCh:
-			if sTime.After(t) {
+		case dep := <-info.timesCh:
+			if info.opts.upToDate(sTime, dep.t) {
 				// Target is more recent
 				// than a given dep
 				continue
@@ -159,21 +246,16 @@ func targetWorker(info *fileInfo, wg *sync.WaitGroup) {
 			)
 			// Doesn't build right after since we 
 			// need to wait for the remaining deps
-			for deps--; deps > 0; deps-- {
-				select {
-				case <-info.panicCh:
-					return
-				case <-info.timesCh:
-				}
+			if info.waitDeps(deps - 1) {
+				info.build(outdated(sTime, dep))
 			}
-			info.build()
 			return
 		}
 	}
 	
 	// There isn't any dep whose uptime
 	// is greater than the target
-	info.propagate(sTime)
+	info.skip(sTime)
 }
 
 func leafWorker(info *fileInfo, wg *sync.WaitGroup) {
@@ -186,11 +268,12 @@ func leafWorker(info *fileInfo, wg *sync.WaitGroup) {
 	}
 	
 	if t, err := info.Status(info.filename); err == nil {
-		info.propagate(t)
+		info.checkFuture(t)
+		info.skip(t)
 		return
 	}
 	log.Printf("%q doesn't exist. Proceeds to build", info.filename)
-	info.build()
+	info.build(&Event{Reason: ReasonMissing})
 }
 
 func spawnTargetWorkers( 
@@ -198,10 +281,12 @@ func spawnTargetWorkers(
 	fileScan utils.Scan, 
 	errorCh chan *Msg,
 	workersWg *sync.WaitGroup,
+	opts *options,
 ) {
 	initCommonChs := func(info *fileInfo) {
-		info.timesCh = make(chan time.Time, info.dependencies)
+		info.timesCh = make(chan depTime, info.dependencies)
 		info.Scan = fileScan
+		info.opts = opts
 		info.panicCh = make(chan struct{}, 1)
 		info.errorCh = errorCh
 	}
@@ -230,9 +315,11 @@ func spawnLeafWorkers(
 	fileScan utils.Scan, 
 	errorCh chan *Msg,
 	workersWg *sync.WaitGroup,
+	opts *options,
 ) {
 	initCommonChs := func(info *fileInfo) {
 		info.Scan = fileScan
+		info.opts = opts
 		info.panicCh = make(chan struct{}, 1)
 		info.errorCh = errorCh
 	}
@@ -245,8 +332,14 @@ func spawnLeafWorkers(
 			fileInfoCh <-info
 		}
 	}()
+	// WaitGroup is necessary here since the
+	// core manager reads the channels of every
+	// leaf once some worker fails
+	var wg sync.WaitGroup
+	wg.Add(cpus)
 	for c := cpus - 1; c >= 0; c-- {
 		go func(i, j int) {
+			defer wg.Done()
 			// We need to keep using i and 
 			// j since the sz can be odd
 			for n := j - i; n > 0; n-- {
@@ -256,10 +349,42 @@ func spawnLeafWorkers(
 			}
 		}(c * sz / cpus, (c + 1) * sz / cpus)
 	}
+	wg.Wait()
+}
+
+// reject replies with the error, without any build
+func reject(err error, o *options) chan *Msg {
+	log.Printf("Rejecting the graph: %v", err)
+	reqCh := make(chan *Msg, 1)
+	go func() {
+		msg := &Msg{Type: BuildError, Err: err}
+		o.finish(msg)
+		reqCh <- msg
+	}()
+	return reqCh
 }
 
-func MakeController(file *parser.DepFile, fileScan utils.Scan) chan *Msg {
+// MakeController builds the dependency graph, or
+// the sub-graph of the goals, removes the partial
+// outputs of its files and spawns its workers.
+// The returned channel receives the build result,
+// which is an error without any build if some goal,
+// pool or attribute is invalid.
+func MakeController(file *parser.DepFile, fileScan utils.Scan, opts ...Option) chan *Msg {
+	o := newOptions(opts)
 	dG := buildGraph(file)
+	dG.cleanPartials(fileScan)
+
+	if err := dG.prune(o.goals); err != nil {
+		return reject(err, o)
+	}
+	if err := dG.configure(file.Pools); err != nil {
+		return reject(err, o)
+	}
+
+	if o.workers > 0 {
+		return runQueue(dG, fileScan, o)
+	}
 
 	workersN := len(dG.targets) + len(dG.leafs)
 
@@ -271,19 +396,32 @@ func MakeController(file *parser.DepFile, fileScan utils.Scan) chan *Msg {
 
 	spawnTargetWorkers(
 		dG.targets, fileScan, 
-		errorCh, &workersWg, 
+		errorCh, &workersWg, o,
 	)
 
 	spawnLeafWorkers(
 		dG.leafs, fileScan, 
-		errorCh, &workersWg, 
+		errorCh, &workersWg, o,
 	)
 
 	errMsgCh := make(chan *Msg, 1)
+	done := make(chan struct{}) // Every worker ended
 
 	// Core manager 
 	go func() {
-		err := <-errorCh
+		var err *Msg
+		select {
+		case err = <-errorCh:
+		case <-done:
+			// Workers send their error before ending
+			select {
+			case err = <-errorCh:
+			default:
+			}
+			// Sends error, or nil, to reconciler
+			errMsgCh <- err
+			return
+		}
 		// Sends error to reconciler
 		errMsgCh <-err
 	
@@ -306,13 +444,13 @@ func MakeController(file *parser.DepFile, fileScan utils.Scan) chan *Msg {
 	// Reconciler
 	go func() {
 		workersWg.Wait()
-		var msg *Msg
-		select {
-		case msg = <-errMsgCh:
-		default:
+		close(done)
+		msg := <-errMsgCh
+		if msg == nil {
 			// Everything went ok
 			msg = &Msg{Type: BuildSuccess}
 		}
+		o.finish(msg)
 		reqCh <- msg
 	}()
 
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
 	"errors"
 	"fmt"
 	"io"
@@ -35,9 +36,14 @@ type fakeFileInfo struct {
 // Scan represents the worst mock-up ever seen
 type fakeScan struct {
 	files map[string]*fakeFileInfo
+	checked chan string // Optional, receives checked files
+	built chan string // Optional, receives built files
 }
 
 func (s *fakeScan) Status(filename string) (time.Time, error) {
+	if s.checked != nil {
+		s.checked <- filename
+	}
 	info := s.files[filename]
 	if info.time == nil {
 		return time.Time{}, missing
@@ -51,16 +57,25 @@ func (s *fakeScan) Build(filename string) (time.Time, error) {
 	if info.fail {
 		return time.Time{}, &buildError{filename: filename}
 	}
+	if s.built != nil {
+		s.built <- filename
+	}
 
 	return time.Now(), nil // current time used to force build on dependants
 }
 
 // convertTime expects convertTime in the format %d%d
 func convertTime(day string) *time.Time {
-	t, _ := time.Parse("2001-01-01", fmt.Sprintf("2001-01-%s", day))
+	t, _ := time.Parse("2006-01-02", fmt.Sprintf("2001-01-%s", day))
 	return &t
 }
 
+// schedulers are the ways the controller can run
+var schedulers = map[string][]Option{
+	"WorkerPerFile": nil,
+	"Queue":         {WithWorkers(3)},
+}
+
 func TestGraphContent(t *testing.T) {
 	s := `
 r  <- d1 d2;
@@ -173,6 +188,118 @@ d2 <- d3 d4 d5;
 	checkInfo("d5", 0, "d2")
 }
 
+func TestGraphOrderOnly(t *testing.T) {
+	s := `
+phony r <- d1 | d2;
+d1 <- d3 | d2;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	dG := buildGraph(dFile)
+
+	if !dG.nodes["r"].phony {
+		t.Error("Expecting r to be phony")
+	}
+	if dG.nodes["d1"].phony {
+		t.Error("Expecting d1 not to be phony")
+	}
+	if n := dG.nodes["r"].dependencies; n != 2 {
+		t.Errorf("Wrong number of dependencies of r. got=%d, expect=2", n)
+	}
+	if n := dG.nodes["d1"].dependencies; n != 2 {
+		t.Errorf("Wrong number of dependencies of d1. got=%d, expect=2", n)
+	}
+	if deps := dG.nodes["d2"].dependants; len(deps) != 0 {
+		t.Errorf("Expecting no dependants of d2. got=%v", deps)
+	}
+	if deps := dG.nodes["d2"].orderDependants; len(deps) != 2 {
+		t.Errorf("Wrong order-only dependants of d2. got=%v, expect=[r d1]", deps)
+	}
+	if _, ok := dG.leafs["d2"]; !ok {
+		t.Error("Missing d2 in leafs map")
+	}
+}
+
+// drain returns what was sent to ch so far
+func drain(ch chan string) map[string]int {
+	res := make(map[string]int)
+	for {
+		select {
+		case f := <-ch:
+			res[f]++
+		default:
+			return res
+		}
+	}
+}
+
+func TestBuildPhony(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			"test": {},
+			  "r": {time: convertTime("05")},
+			 "d1": {time: convertTime("01")},
+		},
+		checked: make(chan string, 3),
+		built: make(chan string, 3),
+	}
+
+	s := `
+phony test <- r;
+r <- d1;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	msg := <-MakeController(dFile, fileScan)
+	if msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+
+	if n := drain(fileScan.checked)["test"]; n != 0 {
+		t.Errorf("Phony target was checked %d times", n)
+	}
+	built := drain(fileScan.built)
+	if built["test"] != 1 {
+		t.Errorf("Expecting phony target to be built once. got=%d", built["test"])
+	}
+	if built["r"] != 0 {
+		t.Error("Up to date r shouldn't be built")
+	}
+}
+
+func TestBuildOrderOnly(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			 "r": {time: convertTime("05")},
+			"d1": {time: convertTime("01")},
+			"d2": {time: convertTime("20")}, // Newer than r
+			"d3": {},
+		},
+		built: make(chan string, 4),
+	}
+
+	s := `
+r  <- d1 | d2 d3;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	msg := <-MakeController(dFile, fileScan)
+	if msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+
+	built := drain(fileScan.built)
+	if built["d3"] != 1 {
+		t.Error("Missing order-only dependency d3 should be built")
+	}
+	if built["r"] != 0 {
+		t.Error("Order-only dependencies shouldn't trigger a rebuild of r")
+	}
+}
+
 func TestBuildWithoutErrors(t *testing.T) {
 	fileScan := &fakeScan{
 		files: map[string]*fakeFileInfo{
@@ -395,3 +522,46 @@ d23 <- d8 d38 d60 d58 d37 d30 d50;
 	<-tunnel
 }
 
+
+func TestBuildMemScan(t *testing.T) {
+	s := `
+r  <- d1 d2;
+d2 <- d3;
+`
+	dFile, _ := parser.Parse(s)
+
+	for _, opts := range [][]Option{nil, {WithWorkers(2)}} {
+		start := *convertTime("01")
+		scan := utils.NewMemScan(start, time.Minute)
+		defer scan.Close()
+		scan.Set("d1", start)
+		fresh := scan.Snapshot()
+
+		build := func() {
+			if msg := <-MakeController(dFile, scan, opts...); msg.Type != BuildSuccess {
+				t.Fatalf("Got an unnexpected error: %v", msg.Err)
+			}
+		}
+		check := func(when string, builds map[string]int) {
+			for f, n := range builds {
+				if scan.Builds(f) != n {
+					t.Errorf("%s: expecting %q to be built %d times. got=%d", when, f, n, scan.Builds(f))
+				}
+			}
+		}
+
+		build()
+		check("Everything but d1 missing", map[string]int{"r": 1, "d1": 0, "d2": 1, "d3": 1})
+
+		build()
+		check("Up to date", map[string]int{"r": 1, "d1": 0, "d2": 1, "d3": 1})
+
+		scan.Set("d3", scan.Now().Add(time.Hour))
+		build()
+		check("d3 changed", map[string]int{"r": 2, "d1": 0, "d2": 2, "d3": 1})
+
+		scan.Restore(fresh)
+		build()
+		check("Back to the start", map[string]int{"r": 3, "d1": 0, "d2": 3, "d3": 2})
+	}
+}
@@ -0,0 +1,79 @@
+package builder
+
+import "time"
+
+type EventType = string
+
+const (
+	TargetStarted EventType = "target_started"
+	TargetSkipped EventType = "target_skipped"
+	TargetBuilt   EventType = "target_built"
+	TargetFailed  EventType = "target_failed"
+	BuildFinished EventType = "build_finished"
+	FutureModTime EventType = "future_mod_time" // A warning
+)
+
+// Why a target was started
+const (
+	ReasonMissing  = "missing"
+	ReasonPhony    = "phony"
+	ReasonOutdated = "outdated" // Not newer than Cause
+)
+
+// Event describes something that happened during
+// a build. Target is empty on build_finished, which
+// only carries Err if the build went wrong. The
+// target_started events tell the Reason and, for
+// outdated targets, the dep that caused it.
+// future_mod_time warns about a file whose
+// ModTime is in the future.
+type Event struct {
+	Type      EventType     `json:"type"`
+	Target    string        `json:"target,omitempty"`
+	Time      time.Time     `json:"time"`
+	ModTime   *time.Time    `json:"mod_time,omitempty"`    // Of the target, if known
+	Duration  time.Duration `json:"duration_ns,omitempty"` // Spent on Build
+	Err       string        `json:"error,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	Cause     string        `json:"cause,omitempty"`
+	CauseTime *time.Time    `json:"cause_time,omitempty"`
+}
+
+// emit sends an event, if someone is listening
+func (o *options) emit(ev *Event) {
+	if o.events == nil {
+		return
+	}
+	ev.Time = time.Now()
+	o.events <- ev
+}
+
+// finish sends the last event and closes the stream
+func (o *options) finish(msg *Msg) {
+	if o.events == nil {
+		return
+	}
+	ev := &Event{Type: BuildFinished}
+	if msg.Err != nil {
+		ev.Err = msg.Err.Error()
+	}
+	o.emit(ev)
+	close(o.events)
+}
+
+// notify sends an event related to the worker's file
+func (f *fileInfo) notify(ev *Event) {
+	ev.Target = f.filename
+	f.opts.emit(ev)
+}
+
+// outdated explains the build of a target
+// that isn't newer than one of its deps
+func outdated(sTime time.Time, dep depTime) *Event {
+	return &Event{
+		Reason:    ReasonOutdated,
+		ModTime:   &sTime,
+		Cause:     dep.filename,
+		CauseTime: &dep.t,
+	}
+}
@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"testing"
+)
+
+// collect runs a build returning its events by target
+func collect(t *testing.T, s string, fileScan *fakeScan) (*Msg, map[string][]EventType, *Event) {
+	dFile, _ := parser.Parse(s)
+
+	evCh := make(chan *Event, 8)
+	tunnel := MakeController(dFile, fileScan, WithEvents(evCh))
+
+	events := make(map[string][]EventType)
+	var last *Event
+	for ev := range evCh {
+		if last != nil && last.Type == BuildFinished {
+			t.Error("Got an event after build_finished")
+		}
+		if ev.Time.IsZero() {
+			t.Errorf("Event %s of %q without time", ev.Type, ev.Target)
+		}
+		if ev.Target != "" {
+			events[ev.Target] = append(events[ev.Target], ev.Type)
+		}
+		last = ev
+	}
+
+	return <-tunnel, events, last
+}
+
+func checkEvents(t *testing.T, events map[string][]EventType, target string, expect ...EventType) {
+	got := events[target]
+	if len(got) != len(expect) {
+		t.Errorf("Wrong events of %q. got=%v, expect=%v", target, got, expect)
+		return
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Errorf("Wrong events of %q. got=%v, expect=%v", target, got, expect)
+			return
+		}
+	}
+}
+
+func TestEventsWithoutErrors(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			 "r": {time: convertTime("05")},
+			"d1": {time: convertTime("04")},
+			"d2": {time: convertTime("01")},
+			"d3": {},
+		},
+	}
+
+	s := `
+r  <- d1 d3;
+d1 <- d2;
+`
+
+	msg, events, last := collect(t, s, fileScan)
+	if msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+
+	checkEvents(t, events, "d2", TargetSkipped)
+	checkEvents(t, events, "d1", TargetSkipped)
+	checkEvents(t, events, "d3", TargetStarted, TargetBuilt)
+	checkEvents(t, events, "r", TargetStarted, TargetBuilt)
+
+	if last == nil || last.Type != BuildFinished {
+		t.Fatal("Expecting build_finished as the last event")
+	}
+	if last.Err != "" {
+		t.Errorf("Unexpected error in build_finished: %s", last.Err)
+	}
+}
+
+func TestEventsWithErrors(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			 "r": {time: convertTime("05")},
+			"d1": {fail: true},
+		},
+	}
+
+	s := `
+r  <- d1;
+`
+
+	msg, events, last := collect(t, s, fileScan)
+	if msg.Type != BuildError {
+		t.Fatal("Expecting message of type BuildError")
+	}
+
+	checkEvents(t, events, "d1", TargetStarted, TargetFailed)
+	checkEvents(t, events, "r")
+
+	if last == nil || last.Type != BuildFinished {
+		t.Fatal("Expecting build_finished as the last event")
+	}
+	if last.Err != msg.Err.Error() {
+		t.Errorf("Wrong error in build_finished. got=%q, expect=%q", last.Err, msg.Err)
+	}
+}
@@ -0,0 +1,54 @@
+package builder
+
+import "log"
+
+// prune leaves out of the graph every file that
+// none of the goals needs. Nothing is left out
+// if there are no goals.
+func (dG *depGraph) prune(goals []string) error {
+	if len(goals) == 0 {
+		return nil
+	}
+
+	keep := make(map[string]*fileInfo)
+	for _, goal := range goals {
+		nodes, err := dG.subGraph(goal)
+		if err != nil {
+			return err
+		}
+		for filename, info := range nodes {
+			keep[filename] = info
+		}
+	}
+
+	// Dependants that are left out would never
+	// receive the time of their deps
+	needed := func(files []string) []string {
+		res := make([]string, 0, len(files))
+		for _, f := range files {
+			if _, ok := keep[f]; ok {
+				res = append(res, f)
+			}
+		}
+		return res
+	}
+	for filename, info := range dG.nodes {
+		if _, ok := keep[filename]; !ok {
+			delete(dG.nodes, filename)
+			delete(dG.leafs, filename)
+			continue
+		}
+		info.dependants = needed(info.dependants)
+		info.orderDependants = needed(info.orderDependants)
+	}
+	targets := dG.targets[:0]
+	for _, info := range dG.targets {
+		if _, ok := keep[info.filename]; ok {
+			targets = append(targets, info)
+		}
+	}
+	dG.targets = targets
+
+	log.Printf("Building %d files needed by %v", len(dG.nodes), goals)
+	return nil
+}
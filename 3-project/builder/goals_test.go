@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"testing"
+)
+
+func TestGoals(t *testing.T) {
+	s := `
+app   <- main.o | dir;
+tests <- app test.o;
+docs  <- doc.md;
+`
+	dFile, _ := parser.Parse(s)
+
+	for name, opts := range map[string][]Option{
+		"workers": nil,
+		"queue":   {WithWorkers(2)},
+	} {
+		cases := []struct {
+			goals []string
+			built []string
+		}{
+			{nil, []string{"app", "main.o", "dir", "tests", "test.o", "docs", "doc.md"}},
+			{[]string{"app"}, []string{"app", "main.o", "dir"}},
+			{[]string{"app", "docs"}, []string{"app", "main.o", "dir", "docs", "doc.md"}},
+			{[]string{"main.o"}, []string{"main.o"}},
+		}
+		for _, c := range cases {
+			files := make(map[string]*fakeFileInfo)
+			for _, f := range []string{"app", "main.o", "dir", "tests", "test.o", "docs", "doc.md"} {
+				files[f] = &fakeFileInfo{}
+			}
+			fileScan := &fakeScan{files: files, built: make(chan string, len(files))}
+
+			msg := <-MakeController(dFile, fileScan, append(opts, WithGoals(c.goals...))...)
+			if msg.Type != BuildSuccess {
+				t.Fatalf("%s %v: got an unnexpected error: %v", name, c.goals, msg.Err)
+			}
+			built := drain(fileScan.built)
+			if len(built) != len(c.built) {
+				t.Errorf("%s %v: expecting %d files to be built. got=%v", name, c.goals, len(c.built), built)
+			}
+			for _, f := range c.built {
+				if built[f] != 1 {
+					t.Errorf("%s %v: expecting %q to be built once. got=%d", name, c.goals, f, built[f])
+				}
+			}
+		}
+	}
+}
+
+func TestUnknownGoal(t *testing.T) {
+	dFile, _ := parser.Parse("app <- main.o;")
+	fileScan := &fakeScan{built: make(chan string, 2)}
+
+	msg := <-MakeController(dFile, fileScan, WithGoals("app", "tests"))
+	if msg.Type != BuildError {
+		t.Fatal("Expecting an unknown goal to be rejected")
+	}
+	if _, ok := msg.Err.(*UnknownTarget); !ok {
+		t.Errorf("Expecting an UnknownTarget error. got=%T: %v", msg.Err, msg.Err)
+	}
+	if built := drain(fileScan.built); len(built) != 0 {
+		t.Errorf("Nothing should be built. got=%v", built)
+	}
+}
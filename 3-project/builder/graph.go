@@ -0,0 +1,200 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"fmt"
+	"sort"
+)
+
+// Graph is a read only view of the
+// dependency graph of a dependency file
+type Graph struct {
+	dG *depGraph
+}
+
+func NewGraph(file *parser.DepFile) *Graph {
+	return &Graph{dG: buildGraph(file)}
+}
+
+// Nodes returns every file of the graph, sorted
+func (g *Graph) Nodes() []string {
+	nodes := make([]string, 0, len(g.dG.nodes))
+	for filename := range g.dG.nodes {
+		nodes = append(nodes, filename)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+type NoPath struct {
+	from, to string
+}
+
+func (e *NoPath) Error() string {
+	return fmt.Sprintf("%q doesn't depend on %q", e.from, e.to)
+}
+
+func (g *Graph) node(filename string) (*fileInfo, error) {
+	info, ok := g.dG.nodes[filename]
+	if !ok {
+		return nil, &UnknownTarget{target: filename}
+	}
+	return info, nil
+}
+
+// allDependants returns every file depending on info
+func (info *fileInfo) allDependants() []string {
+	return append(append([]string{}, info.dependants...), info.orderDependants...)
+}
+
+// walk returns the files reached from filename through
+// next, either only the direct ones or all of them
+func (g *Graph) walk(
+	filename string,
+	transitive bool,
+	next func(*fileInfo) []string,
+) ([]string, error) {
+	info, err := g.node(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{filename: true}
+	var res []string
+	stack := next(info)
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		res = append(res, f)
+		if transitive {
+			stack = append(stack, next(g.dG.nodes[f])...)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// Deps returns the files that target depends on,
+// order-only ones included, sorted
+func (g *Graph) Deps(target string, transitive bool) ([]string, error) {
+	return g.walk(target, transitive, func(info *fileInfo) []string {
+		return info.deps
+	})
+}
+
+// RDeps returns the files depending on filename, sorted
+func (g *Graph) RDeps(filename string, transitive bool) ([]string, error) {
+	return g.walk(filename, transitive, (*fileInfo).allDependants)
+}
+
+// Path returns the shortest chain of deps from
+// one file to another, both of them included
+func (g *Graph) Path(from, to string) ([]string, error) {
+	if _, err := g.node(from); err != nil {
+		return nil, err
+	}
+	if _, err := g.node(to); err != nil {
+		return nil, err
+	}
+
+	// Breadth first, remembering where we came from
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if f == to {
+			var path []string
+			for ; f != ""; f = prev[f] {
+				path = append([]string{f}, path...)
+			}
+			return path, nil
+		}
+		for _, dep := range g.dG.nodes[f].deps {
+			if _, ok := prev[dep]; !ok {
+				prev[dep] = f
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return nil, &NoPath{from: from, to: to}
+}
+
+// Roots returns the files that nothing depends on,
+// i.e. the top of each tree of the forest, sorted
+func (g *Graph) Roots() []string {
+	var roots []string
+	for filename, info := range g.dG.nodes {
+		if len(info.allDependants()) == 0 {
+			roots = append(roots, filename)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// Needed returns the goals and every
+// file they depend on, sorted
+func (g *Graph) Needed(goals []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var res []string
+	for _, goal := range goals {
+		deps, err := g.Deps(goal, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range append(deps, goal) {
+			if !seen[f] {
+				seen[f] = true
+				res = append(res, f)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// Leafs returns the files without rules, sorted
+func (g *Graph) Leafs() []string {
+	leafs := make([]string, 0, len(g.dG.leafs))
+	for filename := range g.dG.leafs {
+		leafs = append(leafs, filename)
+	}
+	sort.Strings(leafs)
+	return leafs
+}
+
+// Topo returns every file in build order, i.e. each
+// file comes after its deps. Ties are sorted by name.
+func (g *Graph) Topo() []string {
+	remaining := make(map[string]int, len(g.dG.nodes))
+	var ready []string
+	for filename, info := range g.dG.nodes {
+		remaining[filename] = info.dependencies
+		if info.dependencies == 0 {
+			ready = append(ready, filename)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]string, 0, len(g.dG.nodes))
+	for len(ready) > 0 {
+		f := ready[0]
+		ready = ready[1:]
+		order = append(order, f)
+
+		var next []string
+		for _, dep := range g.dG.nodes[f].allDependants() {
+			if remaining[dep]--; remaining[dep] == 0 {
+				next = append(next, dep)
+			}
+		}
+		sort.Strings(next)
+		ready = append(ready, next...)
+	}
+	return order
+}
@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"testing"
+)
+
+func TestGraphNodes(t *testing.T) {
+	s := `
+r  <- d2 d1;
+d1 <- d3;
+d2 <- d3 | d4;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	nodes := NewGraph(dFile).Nodes()
+	expect := []string{"d1", "d2", "d3", "d4", "r"}
+	if len(nodes) != len(expect) {
+		t.Fatalf("Wrong nodes. got=%v, expect=%v", nodes, expect)
+	}
+	for i := range expect {
+		if nodes[i] != expect[i] {
+			t.Fatalf("Wrong nodes. got=%v, expect=%v", nodes, expect)
+		}
+	}
+}
+
+func checkList(t *testing.T, what string, got []string, expect ...string) {
+	if len(got) != len(expect) {
+		t.Errorf("Wrong %s. got=%v, expect=%v", what, got, expect)
+		return
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Errorf("Wrong %s. got=%v, expect=%v", what, got, expect)
+			return
+		}
+	}
+}
+
+func TestGraphQueries(t *testing.T) {
+	s := `
+r  <- d1 d2;
+d1 <- d3;
+d2 <- d3 | d4;
+d5 <- d4;
+`
+
+	dFile, _ := parser.Parse(s)
+	g := NewGraph(dFile)
+
+	deps, _ := g.Deps("r", false)
+	checkList(t, "deps of r", deps, "d1", "d2")
+	deps, _ = g.Deps("r", true)
+	checkList(t, "transitive deps of r", deps, "d1", "d2", "d3", "d4")
+	deps, _ = g.Deps("d3", true)
+	checkList(t, "deps of d3", deps)
+
+	rdeps, _ := g.RDeps("d4", false)
+	checkList(t, "rdeps of d4", rdeps, "d2", "d5")
+	rdeps, _ = g.RDeps("d3", true)
+	checkList(t, "transitive rdeps of d3", rdeps, "d1", "d2", "r")
+
+	path, _ := g.Path("r", "d4")
+	checkList(t, "path from r to d4", path, "r", "d2", "d4")
+	path, _ = g.Path("d3", "d3")
+	checkList(t, "path from d3 to itself", path, "d3")
+	if _, err := g.Path("d4", "r"); err == nil {
+		t.Error("Expecting an error since d4 doesn't depend on r")
+	}
+	if _, err := g.Deps("d6", false); err == nil {
+		t.Error("Expecting an error querying an unknown file")
+	}
+
+	checkList(t, "leafs", g.Leafs(), "d3", "d4")
+	checkList(t, "roots", g.Roots(), "d5", "r")
+
+	needed, _ := g.Needed([]string{"d1", "d5"})
+	checkList(t, "needed by d1 and d5", needed, "d1", "d3", "d4", "d5")
+	if _, err := g.Needed([]string{"d6"}); err == nil {
+		t.Error("Expecting an error with an unknown goal")
+	}
+
+	order := g.Topo()
+	checkList(t, "topological order", order, "d3", "d4", "d1", "d2", "d5", "r")
+}
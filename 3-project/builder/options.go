@@ -0,0 +1,87 @@
+package builder
+
+import "time"
+
+type options struct {
+	events    chan<- *Event
+	workers   int // Of the queue scheduler, if positive
+	policy    Policy
+	durations map[string]time.Duration // Expected, of each target
+	goals     []string                 // Every file is built if empty
+	upToDate  Compare
+	now       func() time.Time // Clock of the scan
+}
+
+// Option configures a controller
+type Option func(*options)
+
+// WithEvents makes the controller send its events
+// on ch, which is closed after build_finished and
+// before replying to the build request. Since the
+// workers block on it, ch must be drained.
+func WithEvents(ch chan<- *Event) Option {
+	return func(o *options) {
+		o.events = ch
+	}
+}
+
+// WithWorkers replaces the worker per file with
+// a ready queue served by n workers, which suits
+// huge graphs. A non-positive n is ignored.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithPolicy sets how the queue scheduler picks
+// among the ready files, CriticalPath by default
+func WithPolicy(p Policy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// WithDurations tells how long each file is expected
+// to take, e.g. from previous builds, so the critical
+// path is known. The map must not change meanwhile.
+func WithDurations(d map[string]time.Duration) Option {
+	return func(o *options) {
+		o.durations = d
+	}
+}
+
+// WithGoals builds only the given goals and what
+// they depend on, instead of the whole graph
+func WithGoals(goals ...string) Option {
+	return func(o *options) {
+		o.goals = goals
+	}
+}
+
+// WithCompare sets how the time of a target is
+// compared with the ones of its deps, Strict by
+// default
+func WithCompare(c Compare) Option {
+	return func(o *options) {
+		o.upToDate = c
+	}
+}
+
+// WithClock sets the clock that the modification
+// times come from, time.Now by default, so the
+// ones in the future are told against it, e.g.
+// MemScan.Now for a scan with a virtual clock
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{upToDate: Strict, now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,191 @@
+package builder
+
+import (
+	"cpl_go_proj22/graphgen"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+type hbKind int
+
+const (
+	buildStarted hbKind = iota
+	buildFinished
+)
+
+type hbEvent struct {
+	kind     hbKind
+	filename string
+}
+
+// hbScan records a happens-before log of its builds.
+// Every event is received by a single goroutine, so
+// their order in the log is the order they happened.
+type hbScan struct {
+	times  map[string]time.Time // Read only
+	events chan hbEvent
+	logCh  chan []hbEvent
+}
+
+func newHBScan(times map[string]time.Time) *hbScan {
+	s := &hbScan{
+		times:  times,
+		events: make(chan hbEvent),
+		logCh:  make(chan []hbEvent),
+	}
+	go func() {
+		var log []hbEvent
+		for ev := range s.events {
+			log = append(log, ev)
+		}
+		s.logCh <- log
+	}()
+	return s
+}
+
+func (s *hbScan) Status(filename string) (time.Time, error) {
+	if t, ok := s.times[filename]; ok {
+		return t, nil
+	}
+	return time.Time{}, missing
+}
+
+func (s *hbScan) Build(filename string) (time.Time, error) {
+	s.events <- hbEvent{kind: buildStarted, filename: filename}
+	t := time.Now() // Newer than every existing file
+	s.events <- hbEvent{kind: buildFinished, filename: filename}
+	return t, nil
+}
+
+// log stops the recording, returning what happened
+func (s *hbScan) log() []hbEvent {
+	close(s.events)
+	return <-s.logCh
+}
+
+// expectBuilt returns the files that are out of date:
+// missing ones and the ones that aren't newer than
+// some dep, which is always the case of rebuilt deps
+func expectBuilt(g *graphgen.Graph) map[string]bool {
+	deps := make(map[string][]string)
+	for _, r := range g.File.Rules {
+		deps[r.Object] = r.Deps
+	}
+
+	built := make(map[string]bool)
+	done := make(map[string]bool)
+	var visit func(f string)
+	visit = func(f string) {
+		if done[f] {
+			return
+		}
+		done[f] = true
+		t, ok := g.Times[f]
+		built[f] = !ok
+		for _, dep := range deps[f] {
+			visit(dep)
+			if built[dep] || !t.After(g.Times[dep]) {
+				built[f] = true
+			}
+		}
+	}
+	visit("root")
+	return built
+}
+
+// graphParams generates random graph configs
+type graphParams struct {
+	graphgen.Config
+	Workers int // Of the queue, if positive
+}
+
+func (graphParams) Generate(rnd *rand.Rand, size int) reflect.Value {
+	p := graphParams{
+		Config: graphgen.Config{
+			Nodes:     2 + rnd.Intn(10+size*2),
+			Depth:     1 + rnd.Intn(6),
+			MaxFanIn:  1 + rnd.Intn(6),
+			MaxFanOut: rnd.Intn(6),
+			Existing:  rnd.Float64(),
+			Stale:     rnd.Float64() / 2,
+			Seed:      rnd.Int63(),
+		},
+	}
+	if rnd.Intn(2) == 0 {
+		p.Workers = 1 + rnd.Intn(4)
+	}
+	return reflect.ValueOf(p)
+}
+
+func (p graphParams) String() string {
+	return fmt.Sprintf("%+v", p.Config) + fmt.Sprintf(" workers=%d", p.Workers)
+}
+
+// checkOrder builds a random graph, verifying that
+// each build started after the ones of its deps had
+// finished, and that only out of date files were built
+func checkOrder(t *testing.T, p graphParams) bool {
+	g := graphgen.Generate(p.Config)
+	fileScan := newHBScan(g.Times)
+
+	msg := <-MakeController(g.File, fileScan, WithWorkers(p.Workers))
+	log := fileScan.log()
+	if msg.Type != BuildSuccess {
+		t.Logf("%v: got an unnexpected error: %v", p, msg.Err)
+		return false
+	}
+
+	deps := make(map[string][]string)
+	for _, r := range g.File.Rules {
+		deps[r.Object] = r.Deps
+	}
+
+	expected := expectBuilt(g)
+	finished := make(map[string]bool)
+	built := make(map[string]bool)
+	for _, ev := range log {
+		switch ev.kind {
+		case buildStarted:
+			if built[ev.filename] {
+				t.Logf("%v: %q was built twice", p, ev.filename)
+				return false
+			}
+			built[ev.filename] = true
+			for _, dep := range deps[ev.filename] {
+				// Out of date deps must have finished,
+				// even if they haven't started yet
+				if expected[dep] && !finished[dep] {
+					t.Logf("%v: %q started before %q had finished", p, ev.filename, dep)
+					return false
+				}
+			}
+		case buildFinished:
+			finished[ev.filename] = true
+		}
+	}
+
+	for f, expect := range expected {
+		if built[f] != expect {
+			t.Logf("%v: %q built=%t, expected=%t", p, f, built[f], expect)
+			return false
+		}
+	}
+	return true
+}
+
+func TestDependencyOrder(t *testing.T) {
+	c := &quick.Config{MaxCount: 200}
+	if testing.Short() {
+		c.MaxCount = 20
+	}
+	prop := func(p graphParams) bool {
+		return checkOrder(t, p)
+	}
+	if err := quick.Check(prop, c); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,32 @@
+package builder
+
+import (
+	"cpl_go_proj22/utils"
+	"log"
+)
+
+// cleanPartials removes the partial outputs that
+// interrupted builds left of the files of the graph,
+// if the scan has them. Others are left alone, they
+// may belong to someone else.
+func (dG *depGraph) cleanPartials(fileScan utils.Scan) {
+	scan, ok := fileScan.(utils.PartialScan)
+	if !ok {
+		return
+	}
+	partials, err := scan.Partials()
+	if err != nil {
+		log.Printf("Couldn't look for partial outputs: %v", err)
+		return
+	}
+	for _, p := range partials {
+		if _, ok := dG.nodes[p.Target]; !ok {
+			continue
+		}
+		if err := scan.RemovePartial(p); err != nil {
+			log.Printf("Couldn't remove partial output %q: %v", p.Path, err)
+			continue
+		}
+		log.Printf("Removed %q, left by an interrupted build of %q", p.Path, p.Target)
+	}
+}
@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanPartials(t *testing.T) {
+	dir := t.TempDir()
+	scan, _ := utils.NewFileScan(dir)
+	defer scan.Close()
+	for _, f := range []string{"d1", ".r.tmp-1", ".d1.tmp-2", ".other.tmp-3"} {
+		os.WriteFile(filepath.Join(dir, f), nil, 0644)
+	}
+
+	dFile, _ := parser.Parse("r <- d1;")
+	// A dry run never touches anything
+	if msg := <-MakeController(dFile, utils.DryRun{Scan: scan}); msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+	if partials, _ := scan.Partials(); len(partials) != 3 {
+		t.Errorf("A dry run shouldn't remove partial outputs. got=%d", len(partials))
+	}
+
+	if msg := <-MakeController(dFile, scan); msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+	partials, _ := scan.Partials()
+	if len(partials) != 1 || partials[0].Target != "other" {
+		t.Errorf("Only the partial output of other should be left. got=%v", partials)
+	}
+	if _, err := scan.Status("r"); err != nil {
+		t.Error("r should be built")
+	}
+}
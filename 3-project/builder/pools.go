@@ -0,0 +1,47 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"errors"
+	"fmt"
+)
+
+// InvalidPool is a wrong pool declaration
+type InvalidPool struct {
+	pool *parser.Pool
+	err  error
+}
+
+func (e *InvalidPool) Error() string {
+	return fmt.Sprintf("invalid pool %q: %v", e.pool.Name, e.err)
+}
+
+// makePools returns a semaphore for each declared
+// pool: a channel with as many slots as its depth
+func makePools(pools []*parser.Pool) (map[string]chan struct{}, error) {
+	res := make(map[string]chan struct{}, len(pools))
+	for _, p := range pools {
+		if _, ok := res[p.Name]; ok {
+			return nil, &InvalidPool{pool: p, err: errors.New("declared more than once")}
+		}
+		if p.Depth < 1 {
+			return nil, &InvalidPool{pool: p, err: errors.New("depth must be positive")}
+		}
+		res[p.Name] = make(chan struct{}, p.Depth)
+	}
+	return res, nil
+}
+
+// enterPool waits for a slot in the file's pool
+func (f *fileInfo) enterPool() {
+	if f.pool != nil {
+		f.pool <- struct{}{}
+	}
+}
+
+// leavePool frees the slot taken by enterPool
+func (f *fileInfo) leavePool() {
+	if f.pool != nil {
+		<-f.pool
+	}
+}
@@ -0,0 +1,152 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowScan is an hbScan whose builds take a while,
+// so that the ones that can overlap do
+type slowScan struct {
+	*hbScan
+	delay time.Duration
+}
+
+func (s *slowScan) Build(filename string) (time.Time, error) {
+	s.events <- hbEvent{kind: buildStarted, filename: filename}
+	time.Sleep(s.delay)
+	t := time.Now()
+	s.events <- hbEvent{kind: buildFinished, filename: filename}
+	return t, nil
+}
+
+func TestPools(t *testing.T) {
+	s := `
+pool link = 2;
+r <- l1 l2 l3 l4 l5 c1 c2 c3 c4;
+l1 [pool=link] <- ;
+l2 [pool=link] <- ;
+l3 [pool=link] <- ;
+l4 [pool=link] <- ;
+l5 [pool=link] <- ;
+c1 <- ;
+c2 <- ;
+c3 <- ;
+c4 <- ;
+`
+	dFile, err := parser.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string][]Option{
+		"WorkerPerFile": nil,
+		"Queue":         {WithWorkers(6)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fileScan := &slowScan{hbScan: newHBScan(nil), delay: 5 * time.Millisecond}
+
+			msg := <-MakeController(dFile, fileScan, opts...)
+			log := fileScan.log()
+			if msg.Type != BuildSuccess {
+				t.Fatalf("Got an unnexpected error: %v", msg.Err)
+			}
+
+			links, most := 0, 0
+			for _, ev := range log {
+				if !strings.HasPrefix(ev.filename, "l") {
+					continue
+				}
+				if ev.kind == buildStarted {
+					links++
+				} else {
+					links--
+				}
+				if links > most {
+					most = links
+				}
+			}
+			if most > 2 {
+				t.Errorf("Expecting at most 2 links at the same time. got=%d", most)
+			}
+			if len(log) != 20 {
+				t.Errorf("Expecting 10 builds. got=%d events", len(log))
+			}
+		})
+	}
+}
+
+func TestInvalidPools(t *testing.T) {
+	for _, s := range []string{
+		"pool link = 0; r <- d;",
+		"pool link = 2; pool link = 3; r <- d;",
+	} {
+		dFile, err := parser.Parse(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+
+		msg := <-MakeController(dFile, &fakeScan{})
+		if msg.Type != BuildError {
+			t.Fatalf("%s: expecting message of type BuildError", s)
+		}
+		if _, ok := msg.Err.(*InvalidPool); !ok {
+			t.Errorf("%s: Err isn't of type InvalidPool: got=%v", s, msg.Err)
+		}
+	}
+
+	dFile, _ := parser.Parse("pool link = 2; r [pool=cc] <- d;")
+	msg := <-MakeController(dFile, &fakeScan{})
+	if _, ok := msg.Err.(*InvalidAttr); !ok {
+		t.Errorf("Unknown pool: Err isn't of type InvalidAttr: got=%v", msg.Err)
+	}
+}
+
+// gateScan builds a file once the one it waits
+// for has started, giving up after a second
+type gateScan struct {
+	waits   map[string]string // Of a file, the one it waits for
+	started map[string]chan struct{}
+}
+
+func (s *gateScan) Status(filename string) (time.Time, error) {
+	return time.Time{}, missing
+}
+
+func (s *gateScan) Build(filename string) (time.Time, error) {
+	if ch, ok := s.started[filename]; ok {
+		close(ch)
+	}
+	if other, ok := s.waits[filename]; ok {
+		select {
+		case <-s.started[other]:
+		case <-time.After(time.Second):
+			return time.Time{}, &buildError{filename: filename}
+		}
+	}
+	return time.Now(), nil
+}
+
+// With every worker but one waiting for a slot
+// of a full pool, nothing else would be built
+func TestQueuePoolsDontHoldWorkers(t *testing.T) {
+	s := `
+pool link = 1;
+r <- a b c;
+a [pool=link] <- ;
+b [pool=link] <- ;
+c <- ;
+`
+	dFile, _ := parser.Parse(s)
+	fileScan := &gateScan{
+		waits:   map[string]string{"a": "c"},
+		started: map[string]chan struct{}{"c": make(chan struct{})},
+	}
+
+	msg := <-MakeController(dFile, fileScan, WithWorkers(2))
+	if msg.Type != BuildSuccess {
+		t.Errorf("c should be built while a holds the pool: %v", msg.Err)
+	}
+}
@@ -0,0 +1,117 @@
+package builder
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Policy is how the queue scheduler
+// picks among the ready files
+type Policy int
+
+const (
+	// CriticalPath picks the file with the longest
+	// path to the root first, since the build can't
+	// end before that path is done
+	CriticalPath Policy = iota
+	// FIFO picks the files in the order they got ready
+	FIFO
+)
+
+// prioritize sets the priority of every file: the
+// duration of the longest path from it to the root,
+// itself included. Files without a known duration
+// are expected to take the average known one.
+func (dG *depGraph) prioritize(durations map[string]time.Duration) {
+	guess := time.Duration(1)
+	var total time.Duration
+	known := 0
+	for f, d := range durations {
+		if _, ok := dG.nodes[f]; ok {
+			total += d
+			known++
+		}
+	}
+	if known > 0 && total > 0 {
+		guess = total / time.Duration(known)
+	}
+
+	done := make(map[*fileInfo]bool, len(dG.nodes))
+	var visit func(f *fileInfo) time.Duration
+	visit = func(f *fileInfo) time.Duration {
+		if done[f] {
+			return f.priority
+		}
+		done[f] = true
+
+		var longest time.Duration
+		for _, deps := range [][]string{f.dependants, f.orderDependants} {
+			for _, dep := range deps {
+				if p := visit(f.nodes[dep]); p > longest {
+					longest = p
+				}
+			}
+		}
+		d, ok := durations[f.filename]
+		if !ok {
+			d = guess
+		}
+		f.priority = longest + d
+		return f.priority
+	}
+	for _, info := range dG.nodes {
+		visit(info)
+	}
+}
+
+// readyQueue holds the files that can be built.
+// It's a heap on the priority, ties broken by name
+// so that the same graph is built in the same order,
+// unless the policy is FIFO.
+type readyQueue struct {
+	files  []*fileInfo
+	policy Policy
+}
+
+func (q *readyQueue) Len() int { return len(q.files) }
+
+func (q *readyQueue) Less(i, j int) bool {
+	a, b := q.files[i], q.files[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.filename < b.filename
+}
+
+func (q *readyQueue) Swap(i, j int) { q.files[i], q.files[j] = q.files[j], q.files[i] }
+
+func (q *readyQueue) Push(x any) { q.files = append(q.files, x.(*fileInfo)) }
+
+func (q *readyQueue) Pop() any {
+	last := q.files[len(q.files)-1]
+	q.files = q.files[:len(q.files)-1]
+	return last
+}
+
+// push adds a file that got ready
+func (q *readyQueue) push(f *fileInfo) {
+	if q.policy == FIFO {
+		q.files = append(q.files, f)
+		return
+	}
+	heap.Push(q, f)
+}
+
+// next returns the file that goes first
+func (q *readyQueue) next() *fileInfo {
+	return q.files[0]
+}
+
+// pop removes the file returned by next
+func (q *readyQueue) pop() {
+	if q.policy == FIFO {
+		q.files = q.files[1:]
+		return
+	}
+	heap.Pop(q)
+}
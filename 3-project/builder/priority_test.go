@@ -0,0 +1,120 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrioritize(t *testing.T) {
+	s := `
+r  <- c3 a | b;
+c3 <- c2;
+c2 <- c1;
+`
+	dFile, _ := parser.Parse(s)
+	dG := buildGraph(dFile)
+	dG.prioritize(map[string]time.Duration{"r": 1, "c2": 5, "a": 3})
+
+	// Unknown ones take the average, 3
+	expected := map[string]time.Duration{
+		"r": 1, "c3": 4, "c2": 9, "c1": 12, "a": 4, "b": 4,
+	}
+	for f, p := range expected {
+		if dG.nodes[f].priority != p {
+			t.Errorf("Wrong priority of %q. expected=%d, got=%d", f, p, dG.nodes[f].priority)
+		}
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	s := `
+r  <- c3 a b;
+c3 <- c2;
+c2 <- c1;
+`
+	dFile, _ := parser.Parse(s)
+
+	for policy, expected := range map[Policy]string{
+		CriticalPath: "c1 c2 a b c3 r",
+		FIFO:         "a b c1 c2 c3 r",
+	} {
+		fileScan := newHBScan(nil)
+		msg := <-MakeController(dFile, fileScan, WithWorkers(1), WithPolicy(policy))
+		log := fileScan.log()
+		if msg.Type != BuildSuccess {
+			t.Fatalf("Got an unnexpected error: %v", msg.Err)
+		}
+
+		var order []string
+		for _, ev := range log {
+			if ev.kind == buildStarted {
+				order = append(order, ev.filename)
+			}
+		}
+		if got := strings.Join(order, " "); got != expected {
+			t.Errorf("Wrong order with policy %d. expected=%q, got=%q", policy, expected, got)
+		}
+	}
+}
+
+// sleepScan builds missing files, taking their latency
+type sleepScan struct {
+	latency map[string]time.Duration
+}
+
+func (s *sleepScan) Status(filename string) (time.Time, error) {
+	return time.Time{}, missing
+}
+
+func (s *sleepScan) Build(filename string) (time.Time, error) {
+	time.Sleep(s.latency[filename])
+	return time.Now(), nil
+}
+
+// BenchmarkPolicy builds a long chain next to many
+// short independent targets with two workers. FIFO
+// starts the chain once the others are done, while
+// the critical path starts it right away.
+func BenchmarkPolicy(b *testing.B) {
+	disableLog(b)
+
+	const chain, fan = 20, 40
+	latency := make(map[string]time.Duration)
+	var rules []string
+	root := "r <-"
+	for i := 0; i < chain; i++ {
+		f := fmt.Sprintf("c%02d", i)
+		latency[f] = time.Millisecond
+		if i > 0 {
+			rules = append(rules, fmt.Sprintf("%s <- c%02d;", f, i-1))
+		}
+	}
+	root += fmt.Sprintf(" c%02d", chain-1)
+	for i := 0; i < fan; i++ {
+		f := fmt.Sprintf("a%02d", i)
+		latency[f] = time.Millisecond
+		root += " " + f
+	}
+	dFile, err := parser.Parse(root + ";\n" + strings.Join(rules, "\n"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	fileScan := &sleepScan{latency: latency}
+
+	for name, policy := range map[string]Policy{
+		"CriticalPath": CriticalPath,
+		"FIFO":         FIFO,
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				<-MakeController(
+					dFile, fileScan, WithWorkers(2),
+					WithPolicy(policy), WithDurations(latency),
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package builder
+
+import (
+	"cpl_go_proj22/utils"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cycle is the error of files that never got
+// ready, because they depend on each other, or
+// on files that do
+type Cycle struct {
+	targets []string
+}
+
+func (e *Cycle) Error() string {
+	return fmt.Sprintf("dependency cycle, never built: %s", strings.Join(e.targets, ", "))
+}
+
+// result of a job done by a queue worker
+type result struct {
+	info *fileInfo
+	t    time.Time
+	err  error
+}
+
+// check decides if the file needs to be built,
+// given the most recent time of its deps, and
+// returns its (new) time. Same rules as the
+// target and leaf workers.
+func (f *fileInfo) check(newest depTime) (time.Time, error) {
+	if f.phony {
+		log.Printf("%q is phony. Proceeds to build", f.filename)
+		return f.tryBuild(&Event{Reason: ReasonPhony})
+	}
+
+	sTime, err := f.Status(f.filename)
+	if err != nil {
+		log.Printf("%q doesn't exist. Proceeds to build", f.filename)
+		return f.tryBuild(&Event{Reason: ReasonMissing})
+	}
+	f.checkFuture(sTime)
+	if f.dependencies > 0 && !f.opts.upToDate(sTime, newest.t) {
+		log.Printf("%q needs to be built", f.filename)
+		return f.tryBuild(outdated(sTime, newest))
+	}
+
+	f.notify(&Event{Type: TargetSkipped, ModTime: &sTime})
+	return sTime, nil
+}
+
+// queueWorker checks every ready file it receives
+func queueWorker(jobs <-chan *fileInfo, results chan<- *result, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for info := range jobs {
+		t, err := info.check(info.newest)
+		results <- &result{info: info, t: t, err: err}
+	}
+}
+
+// runQueue builds the graph with a fixed pool of
+// workers. A single coordinator owns the number of
+// deps each file is still waiting for, and hands
+// files over to the workers once they're ready,
+// following the policy.
+func runQueue(dG *depGraph, fileScan utils.Scan, o *options) chan *Msg {
+	reqCh := make(chan *Msg, 1)
+
+	if o.policy == CriticalPath {
+		dG.prioritize(o.durations)
+	}
+	var initial []*fileInfo
+	for _, info := range dG.nodes {
+		info.Scan = fileScan
+		info.opts = o
+		info.remaining = info.dependencies
+		info.newest = depTime{}
+		if info.remaining == 0 {
+			initial = append(initial, info)
+		}
+	}
+	// Same graph, same order
+	sort.Slice(initial, func(i, j int) bool {
+		return initial[i].filename < initial[j].filename
+	})
+	ready := &readyQueue{policy: o.policy}
+	for _, info := range initial {
+		ready.push(info)
+	}
+
+	log.Printf(
+		"Spawning %d queue workers for %d files",
+		o.workers, len(dG.nodes),
+	)
+	jobs := make(chan *fileInfo)
+	// Unbuffered, so a worker can't take another job
+	// before its result is in. With a single worker the
+	// jobs are handed out in the same order for the same
+	// graph, with more it depends on which builds end first.
+	results := make(chan *result)
+	var wg sync.WaitGroup
+	wg.Add(o.workers)
+	for w := 0; w < o.workers; w++ {
+		go queueWorker(jobs, results, &wg)
+	}
+
+	// Coordinator
+	go func() {
+		var msg *Msg
+		busy, released := 0, 0
+		// Jobs handed out of each pool, and the ready files
+		// waiting for one of its slots, so that no worker
+		// waits for a slot instead of building something else
+		inPool := make(map[chan struct{}]int)
+		parked := make(map[chan struct{}][]*fileInfo)
+		handle := func(r *result) {
+			busy--
+			if p := r.info.pool; p != nil {
+				inPool[p]--
+				if waiting := parked[p]; len(waiting) > 0 {
+					ready.push(waiting[0])
+					parked[p] = waiting[1:]
+				}
+			}
+			if r.err != nil {
+				if msg == nil {
+					log.Printf("Coordinator has received an error: %v", r.err)
+					msg = &Msg{Type: BuildError, Err: r.err}
+				}
+				return
+			}
+			r.info.release(r.t, ready)
+			released++
+		}
+		for busy > 0 || (ready.Len() > 0 && msg == nil) {
+			// Results already in go first, so the next
+			// job is picked among every file they made
+			// ready, rather than at random by select
+			select {
+			case r := <-results:
+				handle(r)
+				continue
+			default:
+			}
+
+			for ready.Len() > 0 {
+				f := ready.next()
+				if f.pool == nil || inPool[f.pool] < cap(f.pool) {
+					break
+				}
+				ready.pop()
+				parked[f.pool] = append(parked[f.pool], f)
+			}
+
+			// Stops handing jobs after the first error
+			var next *fileInfo
+			var jobsCh chan<- *fileInfo
+			if ready.Len() > 0 && msg == nil {
+				next, jobsCh = ready.next(), jobs
+			}
+
+			select {
+			case jobsCh <- next:
+				ready.pop()
+				busy++
+				if next.pool != nil {
+					inPool[next.pool]++
+				}
+			case r := <-results:
+				handle(r)
+			}
+		}
+		close(jobs)
+		wg.Wait()
+
+		// Files of a cycle wait for each other,
+		// so they never got ready
+		if msg == nil && released != len(dG.nodes) {
+			msg = &Msg{Type: BuildError, Err: dG.cycle()}
+			log.Printf("Coordinator has found a cycle: %v", msg.Err)
+		}
+
+		if msg == nil {
+			msg = &Msg{Type: BuildSuccess}
+		}
+		o.finish(msg)
+		reqCh <- msg
+	}()
+
+	return reqCh
+}
+
+// cycle returns the error of the files that
+// still wait for some of their deps
+func (dG *depGraph) cycle() *Cycle {
+	var targets []string
+	for filename, info := range dG.nodes {
+		if info.remaining > 0 {
+			targets = append(targets, filename)
+		}
+	}
+	sort.Strings(targets)
+	return &Cycle{targets: targets}
+}
+
+// release tells the dependants of the file that it's
+// done, pushing to ready the ones that can start
+func (f *fileInfo) release(t time.Time, ready *readyQueue) {
+	wake := func(dep *fileInfo) {
+		if dep.remaining--; dep.remaining == 0 {
+			ready.push(dep)
+		}
+	}
+	for _, dep := range f.dependants {
+		info := f.nodes[dep]
+		if info.newest.filename == "" || t.After(info.newest.t) {
+			info.newest = depTime{f.filename, t}
+		}
+		wake(info)
+	}
+	// Order-only dependants don't care about the time
+	for _, dep := range f.orderDependants {
+		wake(f.nodes[dep])
+	}
+}
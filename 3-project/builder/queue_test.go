@@ -0,0 +1,162 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"strings"
+	"testing"
+)
+
+func TestQueueWithoutErrors(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			 "r": {time: convertTime("10")},
+			"d1": {time: convertTime("04")},
+			"d2": {time: convertTime("03")},
+			"d3": {time: convertTime("06")},
+			"d4": {time: convertTime("01")},
+			"d5": {},
+		},
+		built: make(chan string, 6),
+	}
+
+	s := `
+r  <- d1 d3 | d5;
+d1 <- d2;
+d3 <- d1 d4;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	msg := <-MakeController(dFile, fileScan, WithWorkers(2))
+	if msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+
+	built := drain(fileScan.built)
+	if len(built) != 1 || built["d5"] != 1 {
+		t.Errorf("Expecting only d5 to be built. got=%v", built)
+	}
+}
+
+func TestQueueRebuild(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			"test": {},
+			   "r": {time: convertTime("10")},
+			  "d1": {time: convertTime("04")},
+			  "d2": {time: convertTime("05")}, // Newer than d1
+			  "d3": {time: convertTime("11")},
+			  "d4": {time: convertTime("01")},
+		},
+		checked: make(chan string, 6),
+		built: make(chan string, 6),
+	}
+
+	s := `
+phony test <- r;
+r  <- d1 d3;
+d1 <- d2;
+d3 <- d4;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	msg := <-MakeController(dFile, fileScan, WithWorkers(3))
+	if msg.Type != BuildSuccess {
+		t.Fatalf("Got an unnexpected error: %v", msg.Err)
+	}
+
+	if n := drain(fileScan.checked)["test"]; n != 0 {
+		t.Errorf("Phony target was checked %d times", n)
+	}
+	built := drain(fileScan.built)
+	for _, f := range []string{"test", "r", "d1"} {
+		if built[f] != 1 {
+			t.Errorf("Expecting %q to be built once. got=%d", f, built[f])
+		}
+	}
+	if len(built) != 3 {
+		t.Errorf("Expecting only test, r and d1 to be built. got=%v", built)
+	}
+}
+
+func TestQueueWithErrors(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			 "r": {time: convertTime("01")},
+			"d1": {time: convertTime("04"), fail: true},
+			"d2": {time: convertTime("03")},
+			"d3": {time: convertTime("06")},
+			"d4": {},
+			"d5": {fail: true},
+			"d6": {time: convertTime("02")},
+			"d7": {},
+			"d8": {time: convertTime("01")},
+		},
+		built: make(chan string, 9),
+	}
+
+	s := `
+r  <- d1 d3 d5 d4 d8;
+d1 <- d2 d7;
+d3 <- d1 d4 d5 d7;
+d5 <- d6 d8;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	msg := <-MakeController(dFile, fileScan, WithWorkers(2))
+	if msg.Type != BuildError {
+		t.Fatal("Expecting message of type BuildError")
+	}
+
+	err, ok := msg.Err.(*buildError)
+	if !ok {
+		t.Fatalf("Err isn't of type buildError: got=%v", err)
+	}
+	if err.filename != "d1" && err.filename != "d5" {
+		t.Fatalf("Expecting build error from d1 or d5. got=%s", err.filename)
+	}
+
+	built := drain(fileScan.built)
+	if built["r"] != 0 || built["d3"] != 0 {
+		t.Errorf("Dependants of failed files were built: %v", built)
+	}
+}
+
+func TestQueueCycle(t *testing.T) {
+	fileScan := &fakeScan{
+		files: map[string]*fakeFileInfo{
+			 "r": {time: convertTime("10")},
+			"d1": {time: convertTime("04")},
+			"d2": {},
+			"d3": {time: convertTime("06")},
+		},
+		built: make(chan string, 4),
+	}
+
+	s := `
+r  <- d1 d2;
+d1 <- d3;
+d3 <- d1;
+`
+
+	dFile, _ := parser.Parse(s)
+
+	msg := <-MakeController(dFile, fileScan, WithWorkers(2))
+	if msg.Type != BuildError {
+		t.Fatal("Expecting message of type BuildError")
+	}
+	err, ok := msg.Err.(*Cycle)
+	if !ok {
+		t.Fatalf("Err isn't of type Cycle: got=%v", msg.Err)
+	}
+	if got := strings.Join(err.targets, " "); got != "d1 d3 r" {
+		t.Errorf("Wrong files of the cycle. got=%q, expect=\"d1 d3 r\"", got)
+	}
+
+	built := drain(fileScan.built)
+	if len(built) != 1 || built["d2"] != 1 {
+		t.Errorf("Expecting only d2 to be built. got=%v", built)
+	}
+}
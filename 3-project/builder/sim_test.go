@@ -0,0 +1,326 @@
+package builder
+
+import (
+	"cpl_go_proj22/graphgen"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+// simConfig scripts a simulated scan
+type simConfig struct {
+	times    map[string]time.Time     // Of the existing files
+	latency  map[string]time.Duration // Virtual time spent building
+	failures map[string]error         // Builds that fail
+	seed     int64                    // Picks the order of the replies
+	start    time.Time                // Of the virtual clock
+}
+
+type simOp int
+
+const (
+	simStatus simOp = iota
+	simBuild
+)
+
+type simReply struct {
+	t   time.Time
+	err error
+}
+
+type simReq struct {
+	op       simOp
+	filename string
+	arrival  int // When the simulation got it
+	reply    chan simReply
+}
+
+// simBuildLog records a build, in simulation steps
+type simBuildLog struct {
+	filename string
+	arrival  int // When it was requested
+	done     int // When it was replied
+	t        time.Time
+	err      error
+}
+
+// simScan is a Scan whose replies are given by a single
+// goroutine, one at a time, in an order picked by a
+// seeded random generator. Builds take virtual time.
+// It runs in a synctest bubble, so it can wait for
+// every other goroutine to be blocked before picking:
+// then no worker that could make progress is missing.
+type simScan struct {
+	reqs   chan *simReq
+	stopCh chan chan []*simBuildLog
+}
+
+func newSimScan(c simConfig) *simScan {
+	s := &simScan{
+		reqs:   make(chan *simReq),
+		stopCh: make(chan chan []*simBuildLog),
+	}
+	go s.run(c)
+	return s
+}
+
+func (s *simScan) run(c simConfig) {
+	rnd := rand.New(rand.NewSource(c.seed))
+	now := c.start
+	times := make(map[string]time.Time, len(c.times))
+	for f, t := range c.times {
+		times[f] = t
+	}
+
+	var pending []*simReq
+	var builds []*simBuildLog
+	step := 0
+	for {
+		// Every request that can be sent is
+		// blocked in a send, until replied
+		synctest.Wait()
+		select {
+		case reply := <-s.stopCh:
+			reply <- builds
+			return
+		default:
+		}
+		for more := true; more; {
+			select {
+			case req := <-s.reqs:
+				req.arrival = step
+				step++
+				pending = append(pending, req)
+			default:
+				more = false
+			}
+		}
+		if len(pending) == 0 {
+			// Deadlocked, the bubble tells
+			select {
+			case req := <-s.reqs:
+				req.arrival = step
+				step++
+				pending = append(pending, req)
+			case reply := <-s.stopCh:
+				reply <- builds
+				return
+			}
+		}
+
+		// Sorted so the pick doesn't
+		// depend on arrival order
+
+		sort.Slice(pending, func(i, j int) bool {
+			if pending[i].filename == pending[j].filename {
+				return pending[i].op < pending[j].op
+			}
+			return pending[i].filename < pending[j].filename
+		})
+		i := rnd.Intn(len(pending))
+		req := pending[i]
+		pending = append(pending[:i], pending[i+1:]...)
+
+		switch req.op {
+		case simStatus:
+			if t, ok := times[req.filename]; ok {
+				req.reply <- simReply{t: t}
+			} else {
+				req.reply <- simReply{err: missing}
+			}
+		case simBuild:
+			latency, ok := c.latency[req.filename]
+			if !ok {
+				latency = time.Second
+			}
+			now = now.Add(latency)
+			err := c.failures[req.filename]
+			if err == nil {
+				times[req.filename] = now
+			}
+			builds = append(builds, &simBuildLog{
+				filename: req.filename,
+				arrival:  req.arrival,
+				done:     step,
+				t:        now,
+				err:      err,
+			})
+			req.reply <- simReply{t: now, err: err}
+		}
+		step++
+	}
+}
+
+func (s *simScan) call(op simOp, filename string) (time.Time, error) {
+	req := &simReq{op: op, filename: filename, reply: make(chan simReply, 1)}
+	s.reqs <- req
+	r := <-req.reply
+	return r.t, r.err
+}
+
+func (s *simScan) Status(filename string) (time.Time, error) {
+	return s.call(simStatus, filename)
+}
+
+func (s *simScan) Build(filename string) (time.Time, error) {
+	return s.call(simBuild, filename)
+}
+
+// stop ends the simulation, returning its builds
+func (s *simScan) stop() []*simBuildLog {
+	reply := make(chan []*simBuildLog)
+	s.stopCh <- reply
+	return <-reply
+}
+
+// simulate builds g with a scripted scan, in a
+// bubble of its own. Fails if the build doesn't
+// terminate (in virtual time).
+func simulate(t *testing.T, g *graphgen.Graph, c simConfig, opts ...Option) (msg *Msg, builds []*simBuildLog) {
+	c.times = g.Times
+	c.start = time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC) // After every file
+
+	synctest.Test(t, func(t *testing.T) {
+		fileScan := newSimScan(c)
+		select {
+		case msg = <-MakeController(g.File, fileScan, opts...):
+			builds = fileScan.stop()
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Build with seed %d didn't terminate", c.seed)
+		}
+	})
+	return msg, builds
+}
+
+// checkInvariants verifies that no file was built twice, that
+// builds only started after the ones of their deps finished
+// and that nothing depending on a failed build was built
+func checkInvariants(t *testing.T, g *graphgen.Graph, builds []*simBuildLog, seed int64) {
+	byFile := make(map[string]*simBuildLog)
+	for _, b := range builds {
+		if _, ok := byFile[b.filename]; ok {
+			t.Errorf("Seed %d: %q was built twice", seed, b.filename)
+		}
+		byFile[b.filename] = b
+	}
+
+	for _, r := range g.File.Rules {
+		b, ok := byFile[r.Object]
+		if !ok {
+			continue
+		}
+		for _, dep := range r.Deps {
+			db, ok := byFile[dep]
+			if !ok {
+				continue
+			}
+			if db.err != nil {
+				t.Errorf("Seed %d: %q was built after %q failed", seed, r.Object, dep)
+			}
+			if db.done > b.arrival {
+				t.Errorf(
+					"Seed %d: %q was built (step %d) before %q was done (step %d)",
+					seed, r.Object, b.arrival, dep, db.done,
+				)
+			}
+		}
+	}
+}
+
+func TestSimulation(t *testing.T) {
+	seeds := int64(20)
+	if testing.Short() {
+		seeds = 5
+	}
+
+	for name, opts := range schedulers {
+		t.Run(name, func(t *testing.T) {
+			for seed := int64(0); seed < seeds; seed++ {
+				g := graphgen.Generate(graphgen.Config{
+					Nodes:    30,
+					Depth:    4,
+					MaxFanIn: 3,
+					Existing: 0.6,
+					Stale:    0.3,
+					Seed:     seed,
+				})
+				latency := make(map[string]time.Duration)
+				for i, f := range g.Nodes {
+					latency[f] = time.Duration(1+i%5) * time.Second
+				}
+
+				msg, builds := simulate(t, g, simConfig{latency: latency, seed: seed}, opts...)
+				if msg.Type != BuildSuccess {
+					t.Fatalf("Seed %d: got an unnexpected error: %v", seed, msg.Err)
+				}
+				checkInvariants(t, g, builds, seed)
+			}
+		})
+	}
+}
+
+func TestSimulationWithFailures(t *testing.T) {
+	seeds := int64(20)
+	if testing.Short() {
+		seeds = 5
+	}
+
+	for name, opts := range schedulers {
+		t.Run(name, func(t *testing.T) {
+			for seed := int64(0); seed < seeds; seed++ {
+				g := graphgen.Generate(graphgen.Config{
+					Nodes:    30,
+					Depth:    4,
+					MaxFanIn: 3,
+					Seed:     seed,
+				})
+				// Nothing exists, so one of them fails for sure
+				failed := []string{g.Nodes[seed%10], g.Nodes[10+seed%10]}
+				failures := make(map[string]error)
+				for _, f := range failed {
+					failures[f] = fmt.Errorf("injected failure on %q", f)
+				}
+
+				msg, builds := simulate(t, g, simConfig{failures: failures, seed: seed}, opts...)
+				if msg.Type != BuildError {
+					t.Fatalf("Seed %d: expecting message of type BuildError", seed)
+				}
+				if msg.Err != failures[failed[0]] && msg.Err != failures[failed[1]] {
+					t.Fatalf("Seed %d: unexpected error: %v", seed, msg.Err)
+				}
+				checkInvariants(t, g, builds, seed)
+			}
+		})
+	}
+}
+
+// TestSimulationIsDeterministic runs the same seed
+// twice, expecting the same builds in the same order
+func TestSimulationIsDeterministic(t *testing.T) {
+	g := graphgen.Generate(graphgen.Config{
+		Nodes:    20,
+		Depth:    3,
+		MaxFanIn: 3,
+		Existing: 0.5,
+		Seed:     42,
+	})
+	c := simConfig{seed: 42}
+
+	for name, opts := range schedulers {
+		t.Run(name, func(t *testing.T) {
+			_, builds1 := simulate(t, g, c, opts...)
+			_, builds2 := simulate(t, g, c, opts...)
+			if len(builds1) != len(builds2) {
+				t.Fatalf("Different number of builds: %d and %d", len(builds1), len(builds2))
+			}
+			for i := range builds1 {
+				if builds1[i].filename != builds2[i].filename || !builds1[i].t.Equal(builds2[i].t) {
+					t.Fatalf("Builds differ at %d: %q and %q", i, builds1[i].filename, builds2[i].filename)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package builder
+
+import (
+	"fmt"
+	"time"
+)
+
+type NotRebuilt struct {
+	target string
+}
+
+func (e *NotRebuilt) Error() string {
+	return fmt.Sprintf("%q wasn't rebuilt", e.target)
+}
+
+const whyTimeFormat = "2006-01-02 15:04:05.000000"
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "?"
+	}
+	return t.Format(whyTimeFormat)
+}
+
+// Explain returns the chain of causes that led to the
+// build of target, from the first one to the target,
+// given the events of a build. Returns NotRebuilt if
+// target wasn't built.
+func Explain(events []*Event, target string) ([]string, error) {
+	started := make(map[string]*Event)
+	ended := make(map[string]*Event) // Built or failed
+	for _, ev := range events {
+		switch ev.Type {
+		case TargetStarted:
+			started[ev.Target] = ev
+		case TargetBuilt, TargetFailed:
+			ended[ev.Target] = ev
+		}
+	}
+	if _, ok := started[target]; !ok {
+		return nil, &NotRebuilt{target: target}
+	}
+
+	var chain []string
+	var explain func(f string)
+	explain = func(f string) {
+		ev := started[f]
+		switch ev.Reason {
+		case ReasonMissing:
+			chain = append(chain, fmt.Sprintf("%s was missing", f))
+		case ReasonPhony:
+			chain = append(chain, fmt.Sprintf("%s is phony, so it's always built", f))
+		case ReasonOutdated:
+			if _, ok := started[ev.Cause]; ok {
+				explain(ev.Cause)
+			} else {
+				chain = append(chain, fmt.Sprintf(
+					"%s was modified at %s", ev.Cause, formatTime(ev.CauseTime),
+				))
+			}
+			chain = append(chain, fmt.Sprintf(
+				"%s (modified at %s) wasn't newer than %s (%s)",
+				f, formatTime(ev.ModTime), ev.Cause, formatTime(ev.CauseTime),
+			))
+		}
+
+		end, ok := ended[f]
+		switch {
+		case !ok:
+			chain = append(chain, fmt.Sprintf("%s was being built", f))
+		case end.Type == TargetFailed:
+			chain = append(chain, fmt.Sprintf("%s failed to build: %s", f, end.Err))
+		default:
+			chain = append(chain, fmt.Sprintf("%s was rebuilt at %s", f, formatTime(end.ModTime)))
+		}
+	}
+	explain(target)
+
+	return chain, nil
+}
@@ -0,0 +1,69 @@
+package builder
+
+import (
+	"cpl_go_proj22/parser"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	s := `
+phony test <- r;
+r  <- d1 d2;
+d1 <- d3;
+`
+
+	for name, opts := range schedulers {
+		fileScan := &fakeScan{
+			files: map[string]*fakeFileInfo{
+				"test": {},
+				   "r": {time: convertTime("05")},
+				  "d1": {time: convertTime("04")},
+				  "d2": {time: convertTime("01")},
+				  "d3": {},
+			},
+		}
+
+		dFile, _ := parser.Parse(s)
+
+		evCh := make(chan *Event, 8)
+		tunnel := MakeController(dFile, fileScan, append(opts, WithEvents(evCh))...)
+		var events []*Event
+		for ev := range evCh {
+			events = append(events, ev)
+		}
+		if msg := <-tunnel; msg.Type != BuildSuccess {
+			t.Fatalf("%s: got an unnexpected error: %v", name, msg.Err)
+		}
+
+		chain, err := Explain(events, "r")
+		if err != nil {
+			t.Fatalf("%s: got an unnexpected error: %v", name, err)
+		}
+		expect := []string{
+			"d3 was missing",
+			"d3 was rebuilt at",
+			"d1 (modified at 2001-01-04 00:00:00.000000) wasn't newer than d3",
+			"d1 was rebuilt at",
+			"r (modified at 2001-01-05 00:00:00.000000) wasn't newer than d1",
+			"r was rebuilt at",
+		}
+		if len(chain) != len(expect) {
+			t.Fatalf("%s: wrong chain. got=%q", name, chain)
+		}
+		for i := range expect {
+			if !strings.HasPrefix(chain[i], expect[i]) {
+				t.Errorf("%s: wrong step %d. got=%q, expect prefix=%q", name, i, chain[i], expect[i])
+			}
+		}
+
+		chain, _ = Explain(events, "test")
+		if len(chain) != 2 || chain[0] != "test is phony, so it's always built" {
+			t.Errorf("%s: wrong chain of phony test. got=%q", name, chain)
+		}
+
+		if _, err := Explain(events, "d2"); err == nil {
+			t.Errorf("%s: expecting an error explaining an up to date file", name)
+		}
+	}
+}
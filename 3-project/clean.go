@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+)
+
+// ownFiles are kept in the files location by the
+// project itself, so they're neither leafs nor
+// built objects
+var ownFiles = map[string]bool{historyFile: true, utils.LockFile: true}
+
+// skipOwnFiles is a scan where the own files don't exist
+type skipOwnFiles struct {
+	utils.CleanScan
+}
+
+func (s skipOwnFiles) Status(filename string) (time.Time, error) {
+	if ownFiles[filename] {
+		return time.Time{}, &fs.PathError{Op: "stat", Path: filename, Err: fs.ErrNotExist}
+	}
+	return s.CleanScan.Status(filename)
+}
+
+func (s skipOwnFiles) Remove(filename string) error {
+	if ownFiles[filename] {
+		return &fs.PathError{Op: "remove", Path: filename, Err: fs.ErrNotExist}
+	}
+	return s.CleanScan.Remove(filename)
+}
+
+// clean removes the built objects of the
+// whole graph or of a target's sub-graph
+func clean(args []string) {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	path := flags.String("d", "", "Files location, (current directory by default)")
+	dryRun := flags.Bool("n", false, "Only list the objects that would be removed")
+	s3 := addS3Flags(flags)
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1 {
+		fmt.Println("Usage: project clean [-d | -s3 url [-s3-endpoint] [-s3-region]] [-n] <location> [target]")
+		os.Exit(0)
+	}
+	if err := s3.check(flags); err != nil {
+		log.Fatal(err.Error())
+	}
+	fileName := args[0]
+	var target string
+	if len(args) > 1 {
+		target = args[1]
+	}
+
+	dFile, err := parser.ParseFile(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	var scan utils.CleanScan
+	if s3.set() {
+		scan, err = s3.scan()
+	} else {
+		scan, err = utils.NewFileScan(*path)
+	}
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	removed, err := builder.Clean(dFile, skipOwnFiles{scan}, target, *dryRun)
+	if c, ok := scan.(io.Closer); ok {
+		c.Close()
+	}
+	for _, f := range removed {
+		if *dryRun {
+			fmt.Printf("Would remove %s\n", f)
+		} else {
+			fmt.Printf("Removed %s\n", f)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Something went wrong with the clean: %v\n", err)
+		os.Exit(1)
+	}
+}
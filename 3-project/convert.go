@@ -0,0 +1,47 @@
+package main
+
+import (
+	"cpl_go_proj22/parser"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// convert translates a dependency file between
+// the DF, JSON and YAML formats
+func convert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := flags.String("to", "", "Format to convert to: df, json or yaml (by the extension of -o by default)")
+	output := flags.String("o", "", "Write the file here instead of stdout")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1 {
+		fmt.Println("Usage: project convert [-to df|json|yaml] [-o file] <location>")
+		os.Exit(0)
+	}
+
+	dFile, err := parser.ParseFile(args[0])
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	f := parser.DetectFormat(*output, nil)
+	if *to != "" {
+		if f, err = parser.ParseFormat(*to); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	res, err := dFile.Encode(f)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if *output == "" {
+		os.Stdout.Write(res)
+		return
+	}
+	if err := os.WriteFile(*output, res, 0644); err != nil {
+		log.Fatal(err.Error())
+	}
+}
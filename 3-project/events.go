@@ -0,0 +1,86 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// sink handles every event of a build
+type sink func(evCh <-chan *builder.Event)
+
+// consume copies the events of evCh to all sinks,
+// each one running on its own goroutine. The returned
+// channel is closed once all of them are done.
+func consume(evCh <-chan *builder.Event, sinks ...sink) <-chan struct{} {
+	var wg sync.WaitGroup
+	chs := make([]chan *builder.Event, len(sinks))
+	for i, s := range sinks {
+		chs[i] = make(chan *builder.Event, cap(evCh))
+		wg.Add(1)
+		go func(s sink, ch <-chan *builder.Event) {
+			defer wg.Done()
+			s(ch)
+		}(s, chs[i])
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for ev := range evCh {
+			for _, ch := range chs {
+				ch <- ev
+			}
+		}
+		for _, ch := range chs {
+			close(ch)
+		}
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// jsonLines writes each event as a JSON line
+func jsonLines(w io.Writer) sink {
+	return func(evCh <-chan *builder.Event) {
+		enc := json.NewEncoder(w)
+		for ev := range evCh {
+			if err := enc.Encode(ev); err != nil {
+				log.Printf("Couldn't write event: %v", err)
+			}
+		}
+	}
+}
+
+// warnings writes the files with modification times
+// in the future once the build is done, so they don't
+// get mixed with the progress display
+func warnings(w io.Writer) sink {
+	return func(evCh <-chan *builder.Event) {
+		var future []*builder.Event
+		for ev := range evCh {
+			if ev.Type == builder.FutureModTime {
+				future = append(future, ev)
+			}
+		}
+		for _, ev := range future {
+			fmt.Fprintf(w, "Warning: %q was modified in the future (%v), builds are wrong until then\n", ev.Target, ev.ModTime)
+		}
+	}
+}
+
+// eventsOutput opens where the events are
+// written to, stdout if file is empty
+func eventsOutput(format, file string) (io.WriteCloser, error) {
+	if format != "jsonl" {
+		return nil, fmt.Errorf("unknown events format %q", format)
+	}
+	if file == "" {
+		return os.Stdout, nil
+	}
+	return os.Create(file)
+}
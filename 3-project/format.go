@@ -0,0 +1,79 @@
+package main
+
+import (
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// format prints the dependency file in
+// canonical form, or rewrites it with -w
+func format(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	sortDeps := flags.Bool("s", false, "Sort the deps of each rule")
+	write := flags.Bool("w", false, "Rewrite the file instead of printing it")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1 {
+		fmt.Println("Usage: project fmt [-s] [-w] <location>")
+		os.Exit(0)
+	}
+	fileName := args[0]
+
+	dFile, err := parser.ParseFile(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	res := dFile.Format(*sortDeps)
+	if !*write {
+		fmt.Print(res)
+		return
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := os.WriteFile(fileName, []byte(res), info.Mode()); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// lint reports the issues of the dependency
+// file, exiting with 1 if there's any
+func lint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	path := flags.String("d", ".", "Files location, to know which leaf files exist")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1 {
+		fmt.Println("Usage: project lint [-d] <location>")
+		os.Exit(0)
+	}
+	fileName := args[0]
+
+	dFile, err := parser.ParseFile(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Only reads, so a build can be running
+	scan, err := utils.NewReadOnlyScan(*path)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	exists := func(f string) bool {
+		_, err := skipOwnFiles{scan}.Status(f)
+		return err == nil
+	}
+	issues := dFile.Lint(exists)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,5 +1,8 @@
 module cpl_go_proj22
 
-go 1.19
+go 1.25.0
 
-require github.com/alecthomas/participle/v2 v2.0.0-beta.5
+require (
+	github.com/alecthomas/participle/v2 v2.0.0-beta.5
+	gopkg.in/yaml.v3 v3.0.1
+)
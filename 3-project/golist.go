@@ -0,0 +1,58 @@
+package main
+
+import (
+	"cpl_go_proj22/golist"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// fromGoList converts the output of "go list -deps
+// -json" into a dependency file, then prints it,
+// writes it or builds it
+func fromGoList(args []string) {
+	flags := flag.NewFlagSet("golist", flag.ExitOnError)
+	std := flags.Bool("std", false, "Keep the packages of the standard library")
+	output := flags.String("o", "", "Write the dependency file here instead of stdout")
+	run := flags.Bool("build", false, "Build the dependency file instead of printing it")
+	bf := addBuildFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: project golist [-std] [-o file | -build [build flags]] [go list output]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	args = flags.Args()
+
+	// Reads stdin by default, e.g. go list -deps -json ./... | project golist
+	var r io.Reader = os.Stdin
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		defer f.Close()
+		r = f
+	}
+
+	pkgs, err := golist.Decode(r)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	dFile, err := golist.Convert(pkgs, *std)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	switch {
+	case *run:
+		bf.build(dFile)
+	case *output != "":
+		if err := os.WriteFile(*output, []byte(dFile.Format(false)), 0644); err != nil {
+			log.Fatal(err.Error())
+		}
+	default:
+		fmt.Print(dFile.Format(false))
+	}
+}
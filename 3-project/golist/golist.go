@@ -0,0 +1,109 @@
+package golist
+
+import (
+	"cpl_go_proj22/parser"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Package is what matters of an entry
+// of the output of "go list -deps -json"
+type Package struct {
+	ImportPath string
+	Imports    []string
+	Standard   bool // Of the standard library
+	DepOnly    bool // Only listed as a dependency
+}
+
+// Decode reads the stream of JSON objects
+// written by "go list -deps -json"
+func Decode(r io.Reader) ([]*Package, error) {
+	var pkgs []*Package
+	dec := json.NewDecoder(r)
+	for {
+		p := &Package{}
+		err := dec.Decode(p)
+		if err == io.EOF {
+			return pkgs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("package %d: %w", len(pkgs)+1, err)
+		}
+		pkgs = append(pkgs, p)
+	}
+}
+
+// Root is the phony target built when
+// more than one package was asked for
+const Root = "all"
+
+// Convert returns a dependency file with a target per
+// package, depending on the packages it imports. Those
+// of the standard library are left out unless std is
+// set, along with imports that weren't listed (e.g.
+// "C"). The root is the package that was asked for,
+// or a phony target depending on all of them. Names
+// are sanitized, and the real import path is kept as
+// a comment if it's different. Returns parser.Clash
+// if two of them end up the same.
+func Convert(pkgs []*Package, std bool) (*parser.DepFile, error) {
+	ids := parser.NewIdents()
+	names := make(map[string]string) // Of the kept import paths
+	var roots []string
+	for _, p := range pkgs {
+		if p.Standard && !std {
+			continue
+		}
+		name, err := ids.Get(p.ImportPath)
+		if err != nil {
+			return nil, err
+		}
+		names[p.ImportPath] = name
+		if !p.DepOnly {
+			roots = append(roots, name)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("no package was asked for")
+	}
+
+	// Dependants before their deps
+	var rules []*parser.Rule
+	for i := len(pkgs) - 1; i >= 0; i-- {
+		p := pkgs[i]
+		name, ok := names[p.ImportPath]
+		if !ok {
+			continue
+		}
+		r := &parser.Rule{Object: name}
+		for _, imp := range p.Imports {
+			if dep, ok := names[imp]; ok {
+				r.Deps = append(r.Deps, dep)
+			}
+		}
+		if name != p.ImportPath {
+			r.Comments = []string{"// " + p.ImportPath}
+		}
+		rules = append(rules, r)
+	}
+
+	if len(roots) > 1 {
+		root := Root
+		for ids.Taken(root) {
+			root += "_"
+		}
+		rules = append([]*parser.Rule{{Phony: true, Object: root, Deps: roots}}, rules...)
+	} else {
+		// The root goes first
+		for i, r := range rules {
+			if r.Object == roots[0] {
+				copy(rules[1:i+1], rules[:i])
+				rules[0] = r
+				break
+			}
+		}
+	}
+	return &parser.DepFile{Rules: rules}, nil
+}
@@ -0,0 +1,110 @@
+package golist
+
+import (
+	"cpl_go_proj22/parser"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testdata/list.json is the output of "go list -deps
+// -json ./parser ./utils", without the unused fields
+func decodeFile(t *testing.T, name string) []*Package {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	pkgs, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgs
+}
+
+func TestConvert(t *testing.T) {
+	pkgs := decodeFile(t, "testdata/list.json")
+
+	dFile, err := Convert(pkgs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `phony all                                 <- cpl_go_proj22_parser cpl_go_proj22_utils;
+
+// cpl_go_proj22/utils
+cpl_go_proj22_utils                       <-;
+
+// cpl_go_proj22/parser
+cpl_go_proj22_parser                      <- github_com_alecthomas_participle_v2 github_com_alecthomas_participle_v2_lexer;
+
+// github.com/alecthomas/participle/v2
+github_com_alecthomas_participle_v2       <- github_com_alecthomas_participle_v2_lexer;
+
+// github.com/alecthomas/participle/v2/lexer
+github_com_alecthomas_participle_v2_lexer <-;
+`
+	if res := dFile.Format(false); res != expected {
+		t.Errorf("Wrong dependency file. expected=\n%s\ngot=\n%s", expected, res)
+	}
+}
+
+func TestConvertStd(t *testing.T) {
+	pkgs := decodeFile(t, "testdata/list.json")
+
+	dFile, err := Convert(pkgs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dFile.Rules) != len(pkgs)+1 {
+		t.Errorf("Expecting a rule per package and the root. got=%d", len(dFile.Rules))
+	}
+	for _, r := range dFile.Rules {
+		if r.Object == "fmt" {
+			if strings.Join(r.Deps, " ") != "errors internal_fmtsort internal_stringslite io math os reflect slices strconv sync unicode_utf8" {
+				t.Errorf("Wrong deps of fmt. got=%v", r.Deps)
+			}
+			return
+		}
+	}
+	t.Error("fmt wasn't found")
+}
+
+func TestConvertSingleRoot(t *testing.T) {
+	s := `{"ImportPath": "example.com/app", "Imports": ["C", "example.com/app/lib", "fmt"]}`
+	pkgs, err := Decode(strings.NewReader(`
+{"ImportPath": "fmt", "Standard": true, "DepOnly": true}
+{"ImportPath": "example.com/app/lib", "DepOnly": true}
+` + s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dFile, err := Convert(pkgs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dFile.Rules) != 2 || dFile.Rules[0].Object != "example_com_app" {
+		t.Fatalf("The package asked for should be the root. got=\n%s", dFile)
+	}
+	if strings.Join(dFile.Rules[0].Deps, " ") != "example_com_app_lib" {
+		t.Errorf("Wrong deps of the root. got=%v", dFile.Rules[0].Deps)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	if _, err := Decode(strings.NewReader(`{"ImportPath": "a"} {"ImportPath": `)); err == nil {
+		t.Error("Expecting an error with broken JSON")
+	}
+
+	pkgs, _ := Decode(strings.NewReader(`{"ImportPath": "a/b"} {"ImportPath": "a_b"}`))
+	if _, err := Convert(pkgs, false); err == nil {
+		t.Error("Expecting a clash between a/b and a_b")
+	} else if _, ok := err.(*parser.Clash); !ok {
+		t.Errorf("Err isn't of type Clash: got=%v", err)
+	}
+
+	pkgs, _ = Decode(strings.NewReader(`{"ImportPath": "fmt", "Standard": true}`))
+	if _, err := Convert(pkgs, false); err == nil {
+		t.Error("Expecting an error without packages")
+	}
+}
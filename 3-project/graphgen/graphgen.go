@@ -0,0 +1,145 @@
+package graphgen
+
+import (
+	"cpl_go_proj22/parser"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Config of a random dependency graph
+type Config struct {
+	Nodes     int     // Files, the root included
+	Depth     int     // Layers of files below the root
+	MaxFanIn  int     // Deps of each target
+	MaxFanOut int     // Dependants of each file, unlimited if 0
+	Existing  float64 // Fraction of files that exist
+	Stale     float64 // Fraction of existing targets older than their deps
+	Seed      int64
+}
+
+// Graph is a generated dependency file along
+// with the state of its files
+type Graph struct {
+	File  *parser.DepFile
+	Nodes []string
+	Times map[string]time.Time // Of the existing files
+}
+
+// base is the time of the oldest files
+var base = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func name(layer, i int) string {
+	return fmt.Sprintf("n%d_%d", layer, i)
+}
+
+// Generate returns a random acyclic graph. Files are
+// split in layers, the first one holding the leafs,
+// and targets depend on files of lower layers, always
+// on one of the layer right below. The root depends
+// on every file without dependants. Files of upper
+// layers are newer, except for stale targets.
+func Generate(c Config) *Graph {
+	if c.Depth < 1 {
+		c.Depth = 1
+	}
+	if c.Nodes < c.Depth+1 {
+		c.Nodes = c.Depth + 1
+	}
+	if c.MaxFanIn < 1 {
+		c.MaxFanIn = 1
+	}
+	rnd := rand.New(rand.NewSource(c.Seed))
+
+	// Spreads the files, but the root, over the layers
+	layers := make([][]string, c.Depth)
+	for i := 0; i < c.Nodes-1; i++ {
+		layer := i % c.Depth
+		layers[layer] = append(layers[layer], name(layer, len(layers[layer])))
+	}
+
+	g := &Graph{
+		File:  &parser.DepFile{},
+		Times: make(map[string]time.Time),
+	}
+	fanOut := make(map[string]int)
+	full := func(dep string) bool {
+		return c.MaxFanOut > 0 && fanOut[dep] >= c.MaxFanOut
+	}
+
+	// pick returns an available file of a random
+	// layer in [low, high], giving up after a while
+	pick := func(low, high int) (string, bool) {
+		for tries := 0; tries < 8; tries++ {
+			layer := layers[low+rnd.Intn(high-low+1)]
+			if dep := layer[rnd.Intn(len(layer))]; !full(dep) {
+				return dep, true
+			}
+		}
+		return "", false
+	}
+
+	var rules []*parser.Rule
+	for l := 1; l < c.Depth; l++ {
+		for _, target := range layers[l] {
+			rule := &parser.Rule{Object: target}
+			seen := make(map[string]bool)
+			addDep := func(dep string) {
+				if !seen[dep] {
+					seen[dep] = true
+					fanOut[dep]++
+					rule.Deps = append(rule.Deps, dep)
+				}
+			}
+
+			// Keeps the depth, even if the fan-out
+			// limit has to be exceeded
+			dep, ok := pick(l-1, l-1)
+			if !ok {
+				below := layers[l-1]
+				dep = below[rnd.Intn(len(below))]
+			}
+			addDep(dep)
+			for n := rnd.Intn(c.MaxFanIn); n > 0; n-- {
+				if dep, ok := pick(0, l-1); ok {
+					addDep(dep)
+				}
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	root := &parser.Rule{Object: "root"}
+	for _, layer := range layers {
+		for _, f := range layer {
+			if fanOut[f] == 0 {
+				root.Deps = append(root.Deps, f)
+			}
+		}
+	}
+	g.File.Rules = append([]*parser.Rule{root}, rules...)
+
+	// Files of layer l are l hours
+	// newer than the ones of layer 0
+	stamp := func(f string, l int) {
+		if rnd.Float64() >= c.Existing {
+			return
+		}
+		t := base.Add(time.Duration(l) * time.Hour)
+		if l > 0 && rnd.Float64() < c.Stale {
+			// Even older than stale deps
+			t = base.Add(-time.Duration(l) * time.Hour)
+		}
+		g.Times[f] = t
+	}
+	for l, layer := range layers {
+		for _, f := range layer {
+			stamp(f, l)
+			g.Nodes = append(g.Nodes, f)
+		}
+	}
+	stamp("root", c.Depth)
+	g.Nodes = append(g.Nodes, "root")
+
+	return g
+}
@@ -0,0 +1,111 @@
+package graphgen
+
+import (
+	"cpl_go_proj22/parser"
+	"testing"
+)
+
+func TestShape(t *testing.T) {
+	c := Config{Nodes: 500, Depth: 6, MaxFanIn: 4, MaxFanOut: 5, Seed: 7}
+	g := Generate(c)
+
+	if len(g.Nodes) != c.Nodes {
+		t.Errorf("Wrong number of nodes. got=%d, expect=%d", len(g.Nodes), c.Nodes)
+	}
+	if g.File.Rules[0].Object != "root" {
+		t.Error("First head is not root.")
+	}
+
+	// Heights of every file, leafs are 0
+	rules := make(map[string]*parser.Rule)
+	for _, r := range g.File.Rules {
+		rules[r.Object] = r
+	}
+	heights := make(map[string]int)
+	var height func(f string) int
+	height = func(f string) int {
+		if h, ok := heights[f]; ok {
+			return h
+		}
+		heights[f] = -1 // Cycle guard
+		h := 0
+		if r, ok := rules[f]; ok {
+			for _, dep := range r.Deps {
+				dh := height(dep)
+				if dh < 0 {
+					t.Fatalf("Cycle through %q", dep)
+				}
+				if dh+1 > h {
+					h = dh + 1
+				}
+			}
+		}
+		heights[f] = h
+		return h
+	}
+	if h := height("root"); h != c.Depth {
+		t.Errorf("Wrong depth. got=%d, expect=%d", h, c.Depth)
+	}
+	if len(heights) != c.Nodes {
+		t.Errorf("Only %d of %d nodes are reachable from root", len(heights), c.Nodes)
+	}
+
+	fanOut := make(map[string]int)
+	for _, r := range g.File.Rules[1:] {
+		if len(r.Deps) == 0 || len(r.Deps) > c.MaxFanIn {
+			t.Errorf("Wrong fan-in of %q: %d", r.Object, len(r.Deps))
+		}
+		for _, dep := range r.Deps {
+			fanOut[dep]++
+		}
+	}
+	for f, n := range fanOut {
+		// Fan-out may be exceeded to keep the depth
+		if n > c.MaxFanOut+1 {
+			t.Errorf("Fan-out of %q is too big: %d", f, n)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	c := Config{Nodes: 200, Depth: 4, MaxFanIn: 3, Existing: 0.5, Seed: 3}
+	g1, g2 := Generate(c), Generate(c)
+	if g1.File.String() != g2.File.String() {
+		t.Error("Same seed generated different graphs")
+	}
+	if len(g1.Times) != len(g2.Times) {
+		t.Error("Same seed generated different files")
+	}
+
+	c.Seed = 4
+	if Generate(c).File.String() == g1.File.String() {
+		t.Error("Different seeds generated the same graph")
+	}
+}
+
+func TestTimes(t *testing.T) {
+	g := Generate(Config{Nodes: 300, Depth: 5, MaxFanIn: 3, Existing: 1})
+	if len(g.Times) != len(g.Nodes) {
+		t.Fatalf("Every file should exist. got=%d, expect=%d", len(g.Times), len(g.Nodes))
+	}
+	// Up to date: targets are newer than their deps
+	for _, r := range g.File.Rules {
+		for _, dep := range r.Deps {
+			if !g.Times[r.Object].After(g.Times[dep]) {
+				t.Fatalf("%q isn't newer than %q", r.Object, dep)
+			}
+		}
+	}
+
+	g = Generate(Config{Nodes: 300, Depth: 5, MaxFanIn: 3, Existing: 1, Stale: 1})
+	for _, r := range g.File.Rules {
+		if !g.Times[r.Object].Before(g.Times[r.Deps[0]]) {
+			t.Fatalf("Stale %q isn't older than %q", r.Object, r.Deps[0])
+		}
+	}
+
+	g = Generate(Config{Nodes: 300, Depth: 5, MaxFanIn: 3})
+	if len(g.Times) != 0 {
+		t.Errorf("No file should exist. got=%d", len(g.Times))
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyFile keeps data about previous
+// builds in the files location, by default
+const historyFile = ".build_history.json"
+
+// noHistory as the -history flag keeps none
+const noHistory = "none"
+
+// addHistoryFlag adds -history to the flags
+// of the commands that build or read it
+func addHistoryFlag(flags *flag.FlagSet) *string {
+	return flags.String("history", "", "Where the build history is kept ("+historyFile+" in the files location by default, "+noHistory+" to keep none)")
+}
+
+// historyPath returns the history file of the
+// builds done in dir, empty if there's none
+func historyPath(value, dir string) string {
+	switch value {
+	case "":
+		return filepath.Join(dir, historyFile)
+	case noHistory:
+		return ""
+	}
+	return value
+}
+
+type history struct {
+	path string
+	// How long the last build of each target took
+	Durations map[string]time.Duration `json:"durations"`
+	// Targets started in the last build, and how they ended
+	LastBuild []*builder.Event `json:"last_build"`
+}
+
+// loadHistory reads the history of the builds from
+// path. A missing or broken file means no history,
+// and an empty path that it isn't kept at all.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if data, err := os.ReadFile(h.path); path != "" && err == nil {
+		json.Unmarshal(data, h)
+	}
+	if h.Durations == nil {
+		h.Durations = make(map[string]time.Duration)
+	}
+	return h
+}
+
+// estimates returns a copy of the known durations
+func (h *history) estimates() map[string]time.Duration {
+	res := make(map[string]time.Duration, len(h.Durations))
+	for target, d := range h.Durations {
+		res[target] = d
+	}
+	return res
+}
+
+// record keeps the duration of every built
+// target and what happened to each of them
+func (h *history) record(evCh <-chan *builder.Event) {
+	h.LastBuild = nil
+	for ev := range evCh {
+		switch ev.Type {
+		case builder.TargetBuilt:
+			h.Durations[ev.Target] = ev.Duration
+			h.LastBuild = append(h.LastBuild, ev)
+		case builder.TargetStarted, builder.TargetFailed:
+			h.LastBuild = append(h.LastBuild, ev)
+		}
+	}
+}
+
+func (h *history) save() error {
+	if h.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, data, 0644)
+}
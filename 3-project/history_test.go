@@ -0,0 +1,50 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryPath(t *testing.T) {
+	for value, expected := range map[string]string{
+		"":          filepath.Join("out", historyFile),
+		noHistory:   "",
+		"hist.json": "hist.json",
+	} {
+		if got := historyPath(value, "out"); got != expected {
+			t.Errorf("Wrong path of %q. got=%q, expect=%q", value, got, expected)
+		}
+	}
+
+	h := loadHistory("")
+	h.Durations["r"] = time.Second
+	if err := h.save(); err != nil {
+		t.Errorf("Keeping no history shouldn't fail: %v", err)
+	}
+}
+
+func TestSkipOwnFiles(t *testing.T) {
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	scan := utils.NewMemScan(start, time.Second)
+	defer scan.Close()
+	for _, f := range []string{historyFile, utils.LockFile, "r"} {
+		scan.Set(f, start)
+	}
+
+	// JSON and YAML files can name it
+	dFile := &parser.DepFile{Rules: []*parser.Rule{{Object: historyFile, Deps: []string{"r"}}}}
+	removed, err := builder.Clean(dFile, skipOwnFiles{scan}, "", false)
+	if err != nil || len(removed) != 0 {
+		t.Errorf("The history shouldn't be removed. got=%v, %v", removed, err)
+	}
+	if _, err := scan.Status(historyFile); err != nil {
+		t.Errorf("The history should still be there: %v", err)
+	}
+	if _, err := (skipOwnFiles{scan}).Status(utils.LockFile); err == nil {
+		t.Error("The lock file shouldn't exist")
+	}
+}
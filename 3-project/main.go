@@ -3,28 +3,63 @@ package main
 import (
 	"cpl_go_proj22/builder"
 	"cpl_go_proj22/parser"
-	"cpl_go_proj22/utils"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 )
 
-func oneShot(c chan *builder.Msg) {
+// commands run by the first argument,
+// otherwise the given file is built
+var commands = map[string]func(args []string){
+	"clean":    clean,
+	"convert":  convert,
+	"fmt":      format,
+	"golist":   fromGoList,
+	"lint":     lint,
+	"makefile": fromMakefile,
+	"ninja":    toNinja,
+	"why":      why,
+}
+
+// oneShot waits for the build and for
+// the ones handling its events
+func oneShot(c chan *builder.Msg, eventsDone <-chan struct{}, w io.Writer) {
 	m := <-c
+	<-eventsDone
 	if m.Type == builder.BuildSuccess {
-		fmt.Println("Build was a success.")
+		fmt.Fprintln(w, "Build was a success.")
 	} else {
-		fmt.Printf("Something went wrong with the build: %v\n", m.Err)
+		fmt.Fprintf(w, "Something went wrong with the build: %v\n", m.Err)
 	}
 }
 
 func main() {
-	path := flag.String("d", "", "Files location, (current directory by default)")
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
+	bf := addBuildFlags(flag.CommandLine)
 	flag.Parse()
 	args := flag.Args()
 	if len(args) < 1 {
-		fmt.Println("Usage: project [-d] <location>")
+		fmt.Println("Usage: project [-d | -s3 url [-s3-endpoint] [-s3-region]] [-wait] [-compare strict|tolerant=<window>|granular] [-j] [-events jsonl [-events-file]] [-progress] [-history file|none] <location> [goal...]")
+		fmt.Println("       project clean [-d | -s3 url [-s3-endpoint] [-s3-region]] [-n] <location> [target]")
+		fmt.Println("       project why [-d | -s3 url [-s3-endpoint] [-s3-region]] <location> <target>")
+		fmt.Println("       project why [-d] -last [-history] <target>")
+		fmt.Println("       project fmt [-s] [-w] <location>")
+		fmt.Println("       project convert [-to df|json|yaml] [-o file] <location>")
+		fmt.Println("       project lint [-d] <location>")
+		fmt.Println("       project golist [-std] [-o file | -build [build flags]] [go list output]")
+		fmt.Println("       project makefile [-o file | -build [build flags]] [Makefile]")
+		fmt.Println("       project ninja [-o file] [-command cmd] [-create-leafs] <location>")
+		for _, q := range queries {
+			fmt.Println("       " + q.usage())
+		}
 		os.Exit(0)
 	}
 	fileName := args[0]
@@ -34,11 +69,5 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
-	var scan *utils.FileScan
-	if scan, err = utils.NewFileScan(*path); err != nil {
-		log.Fatal(err.Error())
-	}
-
-	ch := builder.MakeController(dFile, scan)
-	oneShot(ch)
+	bf.build(dFile, args[1:]...)
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"cpl_go_proj22/makefile"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// fromMakefile converts a Makefile into a dependency
+// file, then prints it, writes it or builds it. What
+// can't be converted is reported on stderr.
+func fromMakefile(args []string) {
+	flags := flag.NewFlagSet("makefile", flag.ExitOnError)
+	output := flags.String("o", "", "Write the dependency file here instead of stdout")
+	run := flags.Bool("build", false, "Build the dependency file instead of printing it")
+	bf := addBuildFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: project makefile [-o file | -build [build flags]] [Makefile]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	args = flags.Args()
+
+	fileName := "Makefile"
+	if len(args) > 0 {
+		fileName = args[0]
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	dFile, unsupported, err := makefile.Convert(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	for _, u := range unsupported {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", fileName, u)
+	}
+
+	switch {
+	case *run:
+		bf.build(dFile)
+	case *output != "":
+		if err := os.WriteFile(*output, []byte(dFile.Format(false)), 0644); err != nil {
+			log.Fatal(err.Error())
+		}
+	default:
+		fmt.Print(dFile.Format(false))
+	}
+}
@@ -0,0 +1,528 @@
+package makefile
+
+import (
+	"bufio"
+	"cpl_go_proj22/parser"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Unsupported is a construct of the Makefile
+// that was left out of the conversion
+type Unsupported struct {
+	Line int
+	What string
+	Text string
+}
+
+func (u *Unsupported) String() string {
+	return fmt.Sprintf("line %d: %s isn't supported: %s", u.Line, u.What, u.Text)
+}
+
+type variable struct {
+	value     string
+	recursive bool // Expanded when used
+}
+
+// rule is an explicit rule, merging the
+// ones of the same target
+type rule struct {
+	deps       []string
+	orderOnly  []string
+	recipe     []string
+	recipeLine int // Of the rule the recipe belongs to
+}
+
+// pattern is a rule whose target has a "%"
+type pattern struct {
+	target    string
+	deps      []string
+	orderOnly []string
+	recipe    []string
+}
+
+type converter struct {
+	vars        map[string]*variable
+	rules       map[string]*rule
+	order       []string // Targets, in order of appearance
+	patterns    []*pattern
+	phony       map[string]bool
+	unsupported []*Unsupported
+
+	// Of the line being read
+	line int
+	text string
+}
+
+var (
+	assignRe  = regexp.MustCompile(`^([^\s:#=+?!]+)\s*(::=|:=|\?=|\+=|!=|=)\s*(.*)$`)
+	specialRe = regexp.MustCompile(`^\.[A-Z][A-Z_]*$`)
+	suffixRe  = regexp.MustCompile(`^\.[a-zA-Z0-9]+(\.[a-zA-Z0-9]+)?$`)
+	funcRe    = regexp.MustCompile(`\$[({][a-z-]+[ \t]`)
+)
+
+// directives that aren't supported
+var directives = map[string]string{
+	"ifeq":     "conditional",
+	"ifneq":    "conditional",
+	"ifdef":    "conditional",
+	"ifndef":   "conditional",
+	"include":  "include",
+	"-include": "include",
+	"sinclude": "include",
+	"export":   "export",
+	"unexport": "export",
+	"override": "override",
+	"private":  "private",
+	"undefine": "undefine",
+	"vpath":    "vpath",
+	"define":   "multi-line variable",
+}
+
+// Convert reads a Makefile and returns its dependency
+// file, along with what had to be left out of it.
+// Explicit rules (".PHONY" ones included, "|" for
+// order-only prerequisites), variables ("=", ":=",
+// "::=", "?=" and "+=" with "$(V)" and "${V}") and
+// pattern rules ("%.o: %.c") are supported. Recipes
+// are kept as comments. The default goal, which goes
+// first, is the only one declared as default.
+// Conditionals are reported, and both of their
+// branches are read.
+func Convert(r io.Reader) (*parser.DepFile, []*Unsupported, error) {
+	c := &converter{
+		vars:  make(map[string]*variable),
+		rules: make(map[string]*rule),
+		phony: make(map[string]bool),
+	}
+	if err := c.read(r); err != nil {
+		return nil, nil, err
+	}
+	df, err := c.resolve()
+	return df, c.unsupported, err
+}
+
+// report adds what isn't supported, once per line
+func (c *converter) report(what string) {
+	for _, u := range c.unsupported {
+		if u.Line == c.line && u.What == what {
+			return
+		}
+	}
+	c.unsupported = append(c.unsupported, &Unsupported{Line: c.line, What: what, Text: c.text})
+}
+
+// read goes through the logical lines, i.e.
+// with the "\" continuations joined
+func (c *converter) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	var recipeTo func(string) // Of the last rule, if any
+	inDefine := false
+	n := 0
+	for scanner.Scan() {
+		n++
+		c.line, c.text = n, scanner.Text()
+		for strings.HasSuffix(c.text, "\\") && scanner.Scan() {
+			n++
+			c.text = strings.TrimSuffix(c.text, "\\") + " " + strings.TrimLeft(scanner.Text(), " \t")
+		}
+		line := c.text
+
+		if inDefine {
+			inDefine = strings.TrimSpace(line) != "endef"
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			if recipeTo == nil {
+				if strings.TrimSpace(line) != "" {
+					c.report("recipe without a rule")
+				}
+				continue
+			}
+			recipeTo(strings.TrimPrefix(line, "\t"))
+			continue
+		}
+
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		recipeTo = nil
+
+		fields := strings.Fields(line)
+		if what, ok := directives[fields[0]]; ok {
+			c.report(what)
+			inDefine = fields[0] == "define"
+			continue
+		}
+		if fields[0] == "else" || fields[0] == "endif" {
+			continue // Already reported
+		}
+
+		if m := assignRe.FindStringSubmatch(line); m != nil {
+			c.assign(m[1], m[2], m[3])
+			continue
+		}
+		if strings.Contains(line, ":") {
+			recipeTo = c.rule(line)
+			continue
+		}
+		c.report("line")
+	}
+	return scanner.Err()
+}
+
+func (c *converter) assign(name, op, value string) {
+	name = c.expand(name, 0)
+	if funcRe.MatchString(value) {
+		c.report("function or substitution reference")
+	}
+	switch op {
+	case "=":
+		c.vars[name] = &variable{value: value, recursive: true}
+	case ":=", "::=":
+		c.vars[name] = &variable{value: c.expand(value, 0)}
+	case "?=":
+		if _, ok := c.vars[name]; !ok {
+			c.vars[name] = &variable{value: value, recursive: true}
+		}
+	case "+=":
+		v, ok := c.vars[name]
+		if !ok {
+			c.vars[name] = &variable{value: value, recursive: true}
+			return
+		}
+		if !v.recursive {
+			value = c.expand(value, 0)
+		}
+		v.value = strings.TrimSpace(v.value + " " + value)
+	default:
+		c.report("shell assignment")
+	}
+}
+
+// rule reads a rule, returning where its recipe goes
+func (c *converter) rule(line string) func(string) {
+	i := strings.Index(line, ":")
+	if strings.HasPrefix(line[i:], "::") {
+		c.report("double-colon rule")
+		return nil
+	}
+	targets := strings.Fields(c.expand(line[:i], 0))
+	rest := line[i+1:]
+	var recipe []string
+	if j := strings.Index(rest, ";"); j >= 0 {
+		rest, recipe = rest[:j], []string{strings.TrimSpace(rest[j+1:])}
+	}
+	rest = c.expand(rest, 0)
+	switch {
+	case len(targets) == 0:
+		c.report("rule without targets")
+		return nil
+	case strings.Contains(rest, "="):
+		c.report("target-specific variable")
+		return nil
+	case strings.Contains(rest, ":"):
+		c.report("static pattern rule")
+		return nil
+	}
+	var deps, orderOnly []string
+	if j := strings.Index(rest, "|"); j >= 0 {
+		deps, orderOnly = strings.Fields(rest[:j]), strings.Fields(rest[j+1:])
+	} else {
+		deps = strings.Fields(rest)
+	}
+
+	if targets[0] == ".PHONY" {
+		for _, dep := range deps {
+			c.phony[dep] = true
+		}
+		return nil
+	}
+	if specialRe.MatchString(targets[0]) {
+		c.report("special target")
+		return nil
+	}
+	if suffixRe.MatchString(targets[0]) && len(deps) == 0 {
+		c.report("suffix rule")
+		return nil
+	}
+
+	if strings.Contains(targets[0], "%") {
+		var patterns []*pattern
+		for _, t := range targets {
+			if t == "%" {
+				c.report("match-anything rule")
+				return nil
+			}
+			p := &pattern{target: t, deps: deps, orderOnly: orderOnly, recipe: recipe}
+			patterns = append(patterns, p)
+		}
+		c.patterns = append(c.patterns, patterns...)
+		return func(line string) {
+			for _, p := range patterns {
+				p.recipe = append(p.recipe, line)
+			}
+		}
+	}
+
+	var rules []*rule
+	start := c.line
+	for _, t := range targets {
+		r, ok := c.rules[t]
+		if !ok {
+			r = &rule{}
+			c.rules[t] = r
+			c.order = append(c.order, t)
+		}
+		r.deps = append(r.deps, deps...)
+		r.orderOnly = append(r.orderOnly, orderOnly...)
+		if recipe != nil {
+			r.recipe, r.recipeLine = recipe, start
+		}
+		rules = append(rules, r)
+	}
+	return func(line string) {
+		// The last recipe of a target wins
+		for _, r := range rules {
+			if r.recipeLine != start {
+				r.recipe, r.recipeLine = nil, start
+			}
+			r.recipe = append(r.recipe, line)
+		}
+	}
+}
+
+// expand replaces the references to variables.
+// Functions are reported and left out.
+func (c *converter) expand(s string, depth int) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch open := s[i]; open {
+		case '$':
+			b.WriteByte('$')
+		case '(', '{':
+			end := closing(s, i)
+			if end < 0 {
+				c.report("unterminated variable reference")
+				return b.String()
+			}
+			ref := c.expand(s[i+1:end], depth)
+			i = end
+			if strings.ContainsAny(ref, " \t,:") {
+				c.report("function or substitution reference")
+				continue
+			}
+			b.WriteString(c.value(ref, depth))
+		default:
+			b.WriteString(c.value(string(open), depth))
+		}
+	}
+	return b.String()
+}
+
+// closing returns the index of the parenthesis
+// or brace closing the one at i, or -1
+func closing(s string, i int) int {
+	open, close := s[i], byte(')')
+	if open == '{' {
+		close = '}'
+	}
+	nested := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case open:
+			nested++
+		case close:
+			if nested--; nested == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+func (c *converter) value(name string, depth int) string {
+	v, ok := c.vars[name]
+	if !ok {
+		return ""
+	}
+	if !v.recursive {
+		return v.value
+	}
+	if depth > 32 {
+		c.report("recursive variable " + name)
+		return ""
+	}
+	return c.expand(v.value, depth+1)
+}
+
+// match returns the stem of name if
+// the pattern's target matches it
+func (p *pattern) match(name string) (string, bool) {
+	i := strings.Index(p.target, "%")
+	prefix, suffix := p.target[:i], p.target[i+1:]
+	if len(name) < len(prefix)+len(suffix)+1 ||
+		!strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
+
+func substitute(list []string, stem string) []string {
+	res := make([]string, len(list))
+	for i, s := range list {
+		res[i] = strings.Replace(s, "%", stem, 1)
+	}
+	return res
+}
+
+// applyPatterns gives a rule to the files that
+// don't have a recipe, using the first pattern
+// whose target matches them. Like make does, a
+// pattern isn't used twice in the same chain.
+func (c *converter) applyPatterns() {
+	type job struct {
+		name string
+		used map[*pattern]bool
+	}
+	var queue []job
+	done := make(map[string]bool)
+	enqueue := func(names []string, used map[*pattern]bool) {
+		for _, n := range names {
+			if !done[n] {
+				done[n] = true
+				queue = append(queue, job{name: n, used: used})
+			}
+		}
+	}
+	for _, t := range c.order {
+		enqueue([]string{t}, nil)
+	}
+	for _, t := range c.order {
+		enqueue(c.rules[t].deps, nil)
+		enqueue(c.rules[t].orderOnly, nil)
+	}
+
+	for len(queue) > 0 {
+		j := queue[0]
+		queue = queue[1:]
+		r, ok := c.rules[j.name]
+		if (ok && r.recipe != nil) || c.phony[j.name] {
+			continue
+		}
+		for _, p := range c.patterns {
+			stem, match := p.match(j.name)
+			if !match || j.used[p] {
+				continue
+			}
+			if !ok {
+				r = &rule{}
+				c.rules[j.name] = r
+				c.order = append(c.order, j.name)
+			}
+			// The pattern's prerequisites go first
+			deps := substitute(p.deps, stem)
+			orderOnly := substitute(p.orderOnly, stem)
+			r.deps = append(deps, r.deps...)
+			r.orderOnly = append(orderOnly, r.orderOnly...)
+			r.recipe = p.recipe
+
+			used := map[*pattern]bool{p: true}
+			for q := range j.used {
+				used[q] = true
+			}
+			enqueue(deps, used)
+			enqueue(orderOnly, used)
+			break
+		}
+	}
+}
+
+// unique drops the repeated names
+func unique(list []string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// resolve returns the dependency file
+// of what has been read, goal first
+func (c *converter) resolve() (*parser.DepFile, error) {
+	c.applyPatterns()
+	if len(c.order) == 0 {
+		return nil, errors.New("there isn't any rule")
+	}
+
+	goal := c.order[0]
+	if _, ok := c.vars[".DEFAULT_GOAL"]; ok {
+		goal = strings.TrimSpace(c.value(".DEFAULT_GOAL", 0))
+		if _, ok := c.rules[goal]; !ok {
+			return nil, fmt.Errorf("there isn't any rule for the default goal %q", goal)
+		}
+	}
+	order := []string{goal}
+	for _, t := range c.order {
+		if t != goal {
+			order = append(order, t)
+		}
+	}
+
+	ids := parser.NewIdents()
+	idents := func(names []string) ([]string, error) {
+		res := make([]string, len(names))
+		for i, n := range names {
+			id, err := ids.Get(n)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = id
+		}
+		return res, nil
+	}
+
+	df := &parser.DepFile{}
+	for i, t := range order {
+		r := c.rules[t]
+		obj, err := ids.Get(t)
+		if err != nil {
+			return nil, err
+		}
+		rule := &parser.Rule{Object: obj, Phony: c.phony[t]}
+		if rule.Deps, err = idents(unique(r.deps)); err != nil {
+			return nil, err
+		}
+		if rule.OrderOnly, err = idents(unique(r.orderOnly)); err != nil {
+			return nil, err
+		}
+		if obj != t {
+			rule.Comments = append(rule.Comments, "# "+t)
+		}
+		for _, line := range r.recipe {
+			rule.Comments = append(rule.Comments, "#\t"+line)
+		}
+		df.Rules = append(df.Rules, rule)
+		if i == 0 {
+			// Like make, the other roots aren't built
+			df.Default = &parser.Default{Goals: []string{obj}}
+		}
+	}
+	return df, nil
+}
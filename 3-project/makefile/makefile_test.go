@@ -0,0 +1,132 @@
+package makefile
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	f, err := os.Open("testdata/Makefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dFile, unsupported, err := Convert(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `default all;
+
+phony all       <- app;
+
+#	$(CC) $(CFLAGS) -o $@ $^
+app             <- main.o util.o parse.o | build;
+
+#	yacc -o $@ $<
+parse.c         <- parse.y;
+
+#	$(CC) $(CFLAGS) -c $<
+util.o          <- util.c defs.h util.h;
+
+#	mkdir -p $@
+build           <-;
+
+#	rm -f app $(OBJS)
+phony clean     <-;
+
+# docs/index.html
+#	pandoc -o $@ $<
+docs_index.html <- README.md;
+
+#	tar cf $@ $<
+app.tar         <- app;
+
+#	$(CC) $(CFLAGS) -c $<
+main.o          <- main.c defs.h;
+
+#	$(CC) $(CFLAGS) -c $<
+parse.o         <- parse.c defs.h;
+`
+	if res := dFile.Format(false); res != expected {
+		t.Errorf("Wrong dependency file. expected=\n%s\ngot=\n%s", expected, res)
+	}
+
+	reports := []string{
+		"line 28: conditional isn't supported: ifdef DEBUG",
+		"line 32: function or substitution reference isn't supported: SRCS = $(wildcard *.c)",
+		"line 36: target-specific variable isn't supported: install: DESTDIR = /usr/local",
+		"line 37: match-anything rule isn't supported: %: %.sh",
+		"line 38: special target isn't supported: .SUFFIXES:",
+	}
+	if len(unsupported) != len(reports) {
+		t.Fatalf("Expecting %d unsupported constructs. got=%v", len(reports), unsupported)
+	}
+	for i, u := range unsupported {
+		if u.String() != reports[i] {
+			t.Errorf("Wrong report. expected=%q, got=%q", reports[i], u)
+		}
+	}
+}
+
+func TestConvertVariables(t *testing.T) {
+	s := `
+A = $(B) x
+B := b
+C := $(A)
+B = late
+D ?= d
+D ?= not used
+E := e
+E += $(D)
+F = one \
+    two
+$(C) ${E} $(F): $$dollar
+`
+	dFile, unsupported, err := Convert(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsupported) != 0 {
+		t.Errorf("Nothing should be unsupported. got=%v", unsupported)
+	}
+
+	var targets []string
+	for _, r := range dFile.Rules {
+		targets = append(targets, r.Object)
+		if len(r.Deps) != 1 || r.Deps[0] != "_dollar" {
+			t.Errorf("Wrong deps of %q. got=%v", r.Object, r.Deps)
+		}
+	}
+	if got := strings.Join(targets, " "); got != "b x e d one two" {
+		t.Errorf("Wrong targets. got=%q", got)
+	}
+}
+
+func TestConvertGoal(t *testing.T) {
+	s := `
+.DEFAULT_GOAL := test
+build: main.o
+test: build
+	./run-tests
+`
+	dFile, _, err := Convert(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dFile.Rules[0].Object != "test" {
+		t.Errorf("The default goal should be the root. got=%q", dFile.Rules[0].Object)
+	}
+	if goals := dFile.Goals(); len(goals) != 1 || goals[0] != "test" {
+		t.Errorf("The default goal should be the only goal. got=%q", goals)
+	}
+
+	if _, _, err := Convert(strings.NewReader("A = a\n")); err == nil {
+		t.Error("Expecting an error without rules")
+	}
+	if _, _, err := Convert(strings.NewReader("a/b: c\na_b: c\n")); err == nil {
+		t.Error("Expecting a clash between a/b and a_b")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"cpl_go_proj22/ninja"
+	"cpl_go_proj22/parser"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// toNinja exports the dependency file as a build.ninja
+func toNinja(args []string) {
+	flags := flag.NewFlagSet("ninja", flag.ExitOnError)
+	output := flags.String("o", "", "Write the build.ninja here instead of stdout")
+	command := flags.String("command", "", "Command run by each build, ninja's $out is the target (same as the builder by default)")
+	createLeafs := flags.Bool("create-leafs", false, "Give the leafs a build statement, so ninja creates the missing ones instead of failing")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1 {
+		fmt.Println("Usage: project ninja [-o file] [-command cmd] [-create-leafs] <location>")
+		os.Exit(0)
+	}
+
+	dFile, err := parser.ParseFile(args[0])
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := ninja.Write(w, dFile, *command, *createLeafs); err != nil {
+		log.Fatal(err.Error())
+	}
+}
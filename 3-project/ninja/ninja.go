@@ -0,0 +1,139 @@
+package ninja
+
+import (
+	"cpl_go_proj22/parser"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Command does what utils.FileScan does: it writes
+// how many times the file has been built into it
+const Command = `n=$$(cut -d' ' -f1 $out 2>/dev/null || echo -1); echo "$$((n + 1)) times built." > $out`
+
+// UndeclaredPool is an error for targets
+// assigned to a pool that doesn't exist
+type UndeclaredPool struct {
+	target, pool string
+}
+
+func (e *UndeclaredPool) Error() string {
+	return fmt.Sprintf("%q uses the undeclared pool %q", e.target, e.pool)
+}
+
+// Write writes the dependency file as a build.ninja
+// whose builds run command, Command if empty. Leafs
+// are only inputs, so ninja fails if one is missing,
+// unless createLeafs gives them a build statement too
+// (ninja then creates the missing ones with command).
+// Phony targets depend on a phony alias without
+// inputs, which ninja considers always dirty, so
+// they're always built. Order-only deps follow "||"
+// and the goals are the default targets. Comments and
+// the attributes ninja doesn't have are kept as
+// comments.
+func Write(w io.Writer, df *parser.DepFile, command string, createLeafs bool) error {
+	if command == "" {
+		command = Command
+	}
+
+	pools := make(map[string]bool)
+	targets := make(map[string]bool)
+	for _, p := range df.Pools {
+		pools[p.Name] = true
+	}
+	for _, r := range df.Rules {
+		targets[r.Object] = true
+		if pool, ok := r.Attr("pool"); ok && !pools[pool] {
+			return &UndeclaredPool{target: r.Object, pool: pool}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated from a dependency file\n")
+	b.WriteString("ninja_required_version = 1.1\n\n")
+	for _, p := range df.Pools {
+		writeComments(&b, p.Comments, p.Trailing)
+		fmt.Fprintf(&b, "pool %s\n  depth = %d\n\n", p.Name, p.Depth)
+	}
+	fmt.Fprintf(&b, "rule build\n  command = %s\n  description = BUILD $out\n\n", command)
+
+	always := "always"
+	for targets[always] {
+		always += "_"
+	}
+	fmt.Fprintf(&b, "# Never exists, so it's always dirty\nbuild %s: phony\n\n", always)
+
+	var leafs []string
+	seen := make(map[string]bool)
+	for _, r := range df.Rules {
+		for _, dep := range append(append([]string{}, r.Deps...), r.OrderOnly...) {
+			if !targets[dep] && !seen[dep] {
+				seen[dep] = true
+				leafs = append(leafs, dep)
+			}
+		}
+
+		writeComments(&b, r.Comments, r.Trailing)
+		var ignored []string
+		for _, a := range r.Attrs {
+			if a.Key != "pool" && a.Key != "phony" {
+				ignored = append(ignored, a.String())
+			}
+		}
+		if len(ignored) > 0 {
+			fmt.Fprintf(&b, "# Not supported: %s\n", strings.Join(ignored, ", "))
+		}
+
+		b.WriteString("build " + r.Object + ": build")
+		for _, dep := range r.Deps {
+			b.WriteString(" " + dep)
+		}
+		if r.IsPhony() {
+			b.WriteString(" | " + always)
+		}
+		if len(r.OrderOnly) > 0 {
+			b.WriteString(" || " + strings.Join(r.OrderOnly, " "))
+		}
+		b.WriteString("\n")
+		if pool, ok := r.Attr("pool"); ok {
+			b.WriteString("  pool = " + pool + "\n")
+		}
+	}
+
+	if createLeafs && len(leafs) > 0 {
+		b.WriteString("\n# Leafs, only built if missing\n")
+		for _, leaf := range leafs {
+			b.WriteString("build " + leaf + ": build\n")
+		}
+	}
+
+	writeComments(&b, df.Comments, "")
+	if goals := df.Goals(); len(goals) > 0 {
+		b.WriteString("\ndefault " + strings.Join(goals, " ") + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeComments writes comments of any kind as ninja ones
+func writeComments(b *strings.Builder, comments []string, trailing string) {
+	if trailing != "" {
+		comments = append(append([]string{}, comments...), trailing)
+	}
+	for _, c := range comments {
+		switch {
+		case strings.HasPrefix(c, "//"):
+			c = "#" + strings.TrimPrefix(c, "//")
+		case strings.HasPrefix(c, "/*"):
+			c = strings.TrimSuffix(strings.TrimPrefix(c, "/*"), "*/")
+			lines := strings.Split(strings.TrimSpace(c), "\n")
+			for i, l := range lines {
+				lines[i] = "# " + strings.TrimSpace(l)
+			}
+			c = strings.Join(lines, "\n")
+		}
+		b.WriteString(c + "\n")
+	}
+}
@@ -0,0 +1,95 @@
+package ninja
+
+import (
+	"cpl_go_proj22/parser"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	s := `pool link = 2; // memory heavy
+phony all <- app;
+/* The app,
+   linked */
+app [pool=link, retries=2] <- main.o util.o | dir;
+main.o <- main.c;
+util.o <- util.c;
+always <- ;
+# The end`
+
+	dFile, err := parser.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := Write(&b, dFile, "touch $out", false); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# Generated from a dependency file
+ninja_required_version = 1.1
+
+# memory heavy
+pool link
+  depth = 2
+
+rule build
+  command = touch $out
+  description = BUILD $out
+
+# Never exists, so it's always dirty
+build always_: phony
+
+build all: build app | always_
+# The app,
+# linked
+# Not supported: retries=2
+build app: build main.o util.o || dir
+  pool = link
+build main.o: build main.c
+build util.o: build util.c
+build always: build
+# The end
+
+default all always
+`
+	if b.String() != expected {
+		t.Errorf("Wrong build.ninja. expected=\n%s\ngot=\n%s", expected, b.String())
+	}
+
+	// Only the declared goals
+	dFile.Default = &parser.Default{Goals: []string{"app", "always"}}
+	b.Reset()
+	Write(&b, dFile, "", false)
+	if !strings.HasSuffix(b.String(), "\ndefault app always\n") {
+		t.Errorf("Wrong default targets. got=\n%s", b.String())
+	}
+}
+
+func TestWriteCreateLeafs(t *testing.T) {
+	dFile, err := parser.Parse("app <- main.o | dir;\nmain.o <- main.c;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := Write(&b, dFile, "", true); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# Leafs, only built if missing
+build dir: build
+build main.c: build
+`
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("Leafs aren't built. got=\n%s", b.String())
+	}
+}
+
+func TestWriteUndeclaredPool(t *testing.T) {
+	dFile, _ := parser.Parse("app [pool=link] <- main.o;")
+	err := Write(&strings.Builder{}, dFile, "", false)
+	if _, ok := err.(*UndeclaredPool); !ok {
+		t.Errorf("Err isn't of type UndeclaredPool: got=%v", err)
+	}
+}
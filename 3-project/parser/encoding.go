@@ -0,0 +1,424 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/alecthomas/participle/v2/lexer"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Format of a dependency file
+type Format int
+
+const (
+	DF   Format = iota // The "target <- deps;" syntax
+	JSON               // Same rules, as JSON
+	YAML               // Or as YAML
+)
+
+var formats = map[string]Format{"df": DF, "json": JSON, "yaml": YAML}
+
+// ParseFormat returns the format with the given
+// name (df, json or yaml)
+func ParseFormat(name string) (Format, error) {
+	f, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return DF, fmt.Errorf("unknown format %q", name)
+	}
+	return f, nil
+}
+
+var (
+	yamlKeyRe = regexp.MustCompile(`^(---|(pools|rules|comments)\s*:)`)
+	valueRe   = regexp.MustCompile(`^[0-9][a-zA-Z0-9.]*$`)
+)
+
+// DetectFormat tells the format of a dependency file
+// by its extension or, if it doesn't say, by its
+// first line that isn't empty or a comment
+func DetectFormat(filename string, data []byte) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return JSON
+	case ".yaml", ".yml":
+		return YAML
+	case ".df":
+		return DF
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "{"):
+			return JSON
+		case yamlKeyRe.MatchString(line):
+			return YAML
+		}
+		return DF
+	}
+	return DF
+}
+
+// SchemaError is a JSON or YAML dependency
+// file that doesn't follow the schema
+type SchemaError struct {
+	Pos lexer.Position
+	Msg string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Schema of the JSON and YAML formats, e.g.
+//
+//	pools:
+//	  - {name: link, depth: 2}
+//	default:
+//	  goals: [app]
+//	rules:
+//	  - target: app
+//	    attrs: [pool=link, retries=2]
+//	    deps: [main.o]
+//	    order_only: [dir]
+//	comments: ["# The end"]
+type (
+	fileDoc struct {
+		Pools    []*poolDoc  `json:"pools,omitempty" yaml:"pools,omitempty"`
+		Default  *defaultDoc `json:"default,omitempty" yaml:"default,omitempty"`
+		Rules    []*ruleDoc  `json:"rules" yaml:"rules"`
+		Comments []string    `json:"comments,omitempty" yaml:"comments,omitempty"`
+	}
+	defaultDoc struct {
+		Goals    []string `json:"goals" yaml:"goals,flow"`
+		Comments []string `json:"comments,omitempty" yaml:"comments,omitempty"`
+		Trailing string   `json:"trailing,omitempty" yaml:"trailing,omitempty"`
+	}
+	poolDoc struct {
+		Name     string   `json:"name" yaml:"name"`
+		Depth    int      `json:"depth" yaml:"depth"`
+		Comments []string `json:"comments,omitempty" yaml:"comments,omitempty"`
+		Trailing string   `json:"trailing,omitempty" yaml:"trailing,omitempty"`
+	}
+	ruleDoc struct {
+		Target    string   `json:"target" yaml:"target"`
+		Phony     bool     `json:"phony,omitempty" yaml:"phony,omitempty"`
+		Attrs     []string `json:"attrs,omitempty" yaml:"attrs,omitempty,flow"`
+		Deps      []string `json:"deps" yaml:"deps,flow"`
+		OrderOnly []string `json:"order_only,omitempty" yaml:"order_only,omitempty,flow"`
+		Comments  []string `json:"comments,omitempty" yaml:"comments,omitempty"`
+		Trailing  string   `json:"trailing,omitempty" yaml:"trailing,omitempty"`
+	}
+)
+
+// Encode returns the dependency file in the format
+func (df *DepFile) Encode(f Format) ([]byte, error) {
+	if f == DF {
+		return []byte(df.Format(false)), nil
+	}
+
+	doc := &fileDoc{Comments: df.Comments}
+	for _, p := range df.Pools {
+		doc.Pools = append(doc.Pools, &poolDoc{
+			Name: p.Name, Depth: p.Depth,
+			Comments: p.Comments, Trailing: p.Trailing,
+		})
+	}
+	if d := df.Default; d != nil {
+		doc.Default = &defaultDoc{Goals: d.Goals, Comments: d.Comments, Trailing: d.Trailing}
+	}
+	for _, r := range df.Rules {
+		rd := &ruleDoc{
+			Target: r.Object, Phony: r.Phony,
+			Deps: r.Deps, OrderOnly: r.OrderOnly,
+			Comments: r.Comments, Trailing: r.Trailing,
+		}
+		if rd.Deps == nil {
+			rd.Deps = []string{}
+		}
+		for _, a := range r.Attrs {
+			rd.Attrs = append(rd.Attrs, a.String())
+		}
+		doc.Rules = append(doc.Rules, rd)
+	}
+
+	if f == JSON {
+		b, err := json.MarshalIndent(doc, "", "  ")
+		return append(b, '\n'), err
+	}
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), enc.Close()
+}
+
+// decode reads a JSON or YAML dependency file. JSON
+// is read as YAML, which keeps the positions.
+func decode(filename string, data []byte) (*DepFile, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, &SchemaError{Pos: lexer.Position{Filename: filename, Line: 1, Column: 1}, Msg: "empty file"}
+	}
+	d := &decoder{filename: filename}
+	return d.file(root.Content[0])
+}
+
+// decoder turns YAML nodes into a DepFile,
+// validating them on the way
+type decoder struct {
+	filename string
+}
+
+func (d *decoder) pos(n *yaml.Node) lexer.Position {
+	return lexer.Position{Filename: d.filename, Line: n.Line, Column: n.Column}
+}
+
+func (d *decoder) errorf(n *yaml.Node, format string, args ...any) error {
+	return &SchemaError{Pos: d.pos(n), Msg: fmt.Sprintf(format, args...)}
+}
+
+// fields calls field with each key and value of a
+// mapping, which can only have the given keys
+func (d *decoder) fields(n *yaml.Node, what string, keys []string, field func(key string, v *yaml.Node) error) error {
+	if n.Kind != yaml.MappingNode {
+		return d.errorf(n, "%s must be a mapping", what)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		known := false
+		for _, key := range keys {
+			known = known || k.Value == key
+		}
+		if !known {
+			return d.errorf(k, "unknown field %q in %s", k.Value, what)
+		}
+		if seen[k.Value] {
+			return d.errorf(k, "field %q set more than once in %s", k.Value, what)
+		}
+		seen[k.Value] = true
+		if err := field(k.Value, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) sequence(n *yaml.Node, what string) ([]*yaml.Node, error) {
+	if n.Kind != yaml.SequenceNode {
+		return nil, d.errorf(n, "%s must be a list", what)
+	}
+	return n.Content, nil
+}
+
+func (d *decoder) str(n *yaml.Node, what string) (string, error) {
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		return "", d.errorf(n, "%s must be a string", what)
+	}
+	return n.Value, nil
+}
+
+func (d *decoder) ident(n *yaml.Node, what string) (string, error) {
+	s, err := d.str(n, what)
+	if err == nil && !IsIdent(s) {
+		err = d.errorf(n, "%s %q isn't a valid name", what, s)
+	}
+	return s, err
+}
+
+func (d *decoder) idents(n *yaml.Node, what string) ([]string, error) {
+	items, err := d.sequence(n, what)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, item := range items {
+		s, err := d.ident(item, "each of "+what)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (d *decoder) strs(n *yaml.Node, what string) ([]string, error) {
+	items, err := d.sequence(n, what)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, item := range items {
+		s, err := d.str(item, "each of "+what)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (d *decoder) file(n *yaml.Node) (*DepFile, error) {
+	df := &DepFile{}
+	hasRules := false
+	err := d.fields(n, "the file", []string{"pools", "default", "rules", "comments"}, func(key string, v *yaml.Node) (err error) {
+		switch key {
+		case "pools":
+			items, err := d.sequence(v, "pools")
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				p, err := d.pool(item)
+				if err != nil {
+					return err
+				}
+				df.Pools = append(df.Pools, p)
+			}
+		case "default":
+			df.Default, err = d.defaults(v)
+		case "rules":
+			items, err := d.sequence(v, "rules")
+			if err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				return d.errorf(v, "there must be at least a rule")
+			}
+			hasRules = true
+			for _, item := range items {
+				r, err := d.rule(item)
+				if err != nil {
+					return err
+				}
+				df.Rules = append(df.Rules, r)
+			}
+		case "comments":
+			df.Comments, err = d.strs(v, "comments")
+		}
+		return err
+	})
+	if err == nil && !hasRules {
+		err = d.errorf(n, "missing field \"rules\"")
+	}
+	return df, err
+}
+
+func (d *decoder) pool(n *yaml.Node) (*Pool, error) {
+	p := &Pool{Pos: d.pos(n)}
+	hasDepth := false
+	err := d.fields(n, "a pool", []string{"name", "depth", "comments", "trailing"}, func(key string, v *yaml.Node) (err error) {
+		switch key {
+		case "name":
+			p.Name, err = d.ident(v, "name")
+		case "depth":
+			if v.Kind != yaml.ScalarNode || v.Tag != "!!int" {
+				return d.errorf(v, "depth must be an integer")
+			}
+			// YAML has ints like 0x2 or 1_000
+			if v.Decode(&p.Depth) != nil {
+				return d.errorf(v, "depth %q doesn't fit an integer", v.Value)
+			}
+			if p.Depth < 1 {
+				return d.errorf(v, "depth must be positive")
+			}
+			hasDepth = true
+		case "comments":
+			p.Comments, err = d.strs(v, "comments")
+		case "trailing":
+			p.Trailing, err = d.str(v, "trailing")
+		}
+		return err
+	})
+	if err == nil && (p.Name == "" || !hasDepth) {
+		err = d.errorf(n, "a pool needs a name and a depth")
+	}
+	return p, err
+}
+
+func (d *decoder) defaults(n *yaml.Node) (*Default, error) {
+	def := &Default{Pos: d.pos(n)}
+	err := d.fields(n, "the default", []string{"goals", "comments", "trailing"}, func(key string, v *yaml.Node) (err error) {
+		switch key {
+		case "goals":
+			def.Goals, err = d.idents(v, "goals")
+		case "comments":
+			def.Comments, err = d.strs(v, "comments")
+		case "trailing":
+			def.Trailing, err = d.str(v, "trailing")
+		}
+		return err
+	})
+	if err == nil && len(def.Goals) == 0 {
+		err = d.errorf(n, "the default needs at least a goal")
+	}
+	return def, err
+}
+
+func (d *decoder) rule(n *yaml.Node) (*Rule, error) {
+	r := &Rule{Pos: d.pos(n)}
+	keys := []string{"target", "phony", "attrs", "deps", "order_only", "comments", "trailing"}
+	err := d.fields(n, "a rule", keys, func(key string, v *yaml.Node) (err error) {
+		switch key {
+		case "target":
+			r.Object, err = d.ident(v, "target")
+		case "phony":
+			if v.Kind != yaml.ScalarNode || v.Tag != "!!bool" {
+				return d.errorf(v, "phony must be true or false")
+			}
+			// YAML has bools like True or TRUE
+			if err := v.Decode(&r.Phony); err != nil {
+				return d.errorf(v, "phony must be true or false")
+			}
+		case "attrs":
+			items, err := d.sequence(v, "attrs")
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				a, err := d.attr(item)
+				if err != nil {
+					return err
+				}
+				r.Attrs = append(r.Attrs, a)
+			}
+		case "deps":
+			r.Deps, err = d.idents(v, "deps")
+		case "order_only":
+			r.OrderOnly, err = d.idents(v, "order_only")
+		case "comments":
+			r.Comments, err = d.strs(v, "comments")
+		case "trailing":
+			r.Trailing, err = d.str(v, "trailing")
+		}
+		return err
+	})
+	if err == nil && r.Object == "" {
+		err = d.errorf(n, "a rule needs a target")
+	}
+	return r, err
+}
+
+// attr reads "key=value" or "key"
+func (d *decoder) attr(n *yaml.Node) (*Attr, error) {
+	s, err := d.str(n, "each attribute")
+	if err != nil {
+		return nil, err
+	}
+	key, value, _ := strings.Cut(s, "=")
+	if !IsIdent(key) || value != "" && !IsIdent(value) && !valueRe.MatchString(value) {
+		return nil, d.errorf(n, "attribute %q isn't key=value", s)
+	}
+	return &Attr{Pos: d.pos(n), Key: key, Value: value}, nil
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const encoded = `pool link = 2; # memory heavy
+
+default all main.o;
+
+// The app
+phony all           <- app;
+app [pool=link, retries=2] <- main.o util.o | dir;
+main.o              <-;
+
+# The end
+`
+
+func TestEncode(t *testing.T) {
+	dFile, err := Parse(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := dFile.Encode(YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `pools:
+  - name: link
+    depth: 2
+    trailing: '# memory heavy'
+default:
+  goals: [all, main.o]
+rules:
+  - target: all
+    phony: true
+    deps: [app]
+    comments:
+      - // The app
+  - target: app
+    attrs: [pool=link, retries=2]
+    deps: [main.o, util.o]
+    order_only: [dir]
+  - target: main.o
+    deps: []
+comments:
+  - '# The end'
+`
+	if string(b) != expected {
+		t.Errorf("Wrong YAML. expected=\n%s\ngot=\n%s", expected, b)
+	}
+
+	for _, f := range []Format{JSON, YAML} {
+		b, err := dFile.Encode(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := decode("", b)
+		if err != nil {
+			t.Fatalf("Format %d: %v", f, err)
+		}
+		if res.Format(false) != dFile.Format(false) {
+			t.Errorf("Format %d doesn't round trip. got=\n%s", f, res.Format(false))
+		}
+	}
+}
+
+func TestParseFileFormats(t *testing.T) {
+	dFile, _ := Parse(encoded)
+	dir := t.TempDir()
+
+	for name, f := range map[string]Format{
+		"deps.json": JSON, "deps.yml": YAML, "deps.df": DF,
+		"json": JSON, "yaml": YAML, "df": DF,
+	} {
+		b, _ := dFile.Encode(f)
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if DetectFormat(file, b) != f {
+			t.Errorf("%s: wrong format", name)
+		}
+		res, err := ParseFile(file)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if res.Format(false) != dFile.Format(false) {
+			t.Errorf("%s: wrong rules. got=\n%s", name, res.Format(false))
+		}
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	for s, expected := range map[string]string{
+		`{"rules": [{"target": "a", "deps": ["b"], "color": "blue"}]}`:    `deps.json:1:43: unknown field "color" in a rule`,
+		"rules:\n  - target: a\n    deps: [b/c]\n":                        `deps.json:3:12: each of deps "b/c" isn't a valid name`,
+		"rules:\n  - deps: [b]\n":                                         `deps.json:2:5: a rule needs a target`,
+		"rules:\n  - target: a\n    phony: yes please\n":                  `deps.json:3:12: phony must be true or false`,
+		"pools:\n  - name: link\n    depth: two\nrules:\n  - target: a\n": `deps.json:3:12: depth must be an integer`,
+		"pools:\n  - name: link\n    depth: 0\nrules:\n  - target: a\n":   `deps.json:3:12: depth must be positive`,
+		"default:\n  goals: []\nrules:\n  - target: a\n":                  `deps.json:2:3: the default needs at least a goal`,
+		"rules: []\n": `deps.json:1:8: there must be at least a rule`,
+		"pools: []\n": `deps.json:1:1: missing field "rules"`,
+		"rules:\n  - target: a\n    attrs: [\"timeout=1 m\"]\n": `deps.json:3:13: attribute "timeout=1 m" isn't key=value`,
+		"rules:\n  - target: a\n    target: b\n":                `deps.json:3:5: field "target" set more than once in a rule`,
+	} {
+		_, err := decode("deps.json", []byte(s))
+		if err == nil {
+			t.Errorf("Expecting an error for %q", s)
+			continue
+		}
+		if _, ok := err.(*SchemaError); !ok || err.Error() != expected {
+			t.Errorf("Wrong error. expected=%q, got=%q", expected, err)
+		}
+	}
+
+	_, err := decode("deps.json", []byte(`{"rules": [`))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expecting a syntax error with its line. got=%v", err)
+	}
+}
+
+func TestYAMLScalars(t *testing.T) {
+	s := "pools:\n  - name: link\n    depth: 0x2\nrules:\n  - target: a\n    phony: True\n"
+	df, err := decode("deps.yaml", []byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.Pools[0].Depth != 2 {
+		t.Errorf("Wrong depth. expected=2, got=%d", df.Pools[0].Depth)
+	}
+	if !df.Rules[0].IsPhony() {
+		t.Errorf("Rule a should be phony")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for s, expected := range map[string]Format{
+		"# deps\n\n{\"rules\": []}": JSON,
+		"---\nrules:\n":             YAML,
+		"  rules:\n":                YAML,
+		"# deps\nrules <- a;\n":     DF,
+		"// deps\nrules:\n":         DF,
+		"":                          DF,
+	} {
+		if f := DetectFormat("deps", []byte(s)); f != expected {
+			t.Errorf("Wrong format of %q. expected=%d, got=%d", s, expected, f)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+)
+
+// Format returns the dependency file in canonical
+// form: pools first, then the default goals and one
+// rule per line, ended by ";", with the arrows
+// aligned. Deps are sorted if asked to, otherwise
+// their order is preserved.
+// Comments are kept, the ones before a rule are
+// preceded by an empty line.
+func (df *DepFile) Format(sortDeps bool) string {
+	var width int
+	for _, r := range df.Rules {
+		if w := len(r.head()); w > width {
+			width = w
+		}
+	}
+
+	var b strings.Builder
+	comments := func(first bool, comments []string) {
+		if len(comments) > 0 && !first {
+			b.WriteString("\n")
+		}
+		for _, c := range comments {
+			b.WriteString(c + "\n")
+		}
+	}
+	trailing := func(c string) {
+		if c != "" {
+			b.WriteString(" " + c)
+		}
+		b.WriteString("\n")
+	}
+
+	for i, p := range df.Pools {
+		comments(i == 0, p.Comments)
+		b.WriteString(p.String() + ";")
+		trailing(p.Trailing)
+	}
+	// section starts the default or the rules,
+	// apart from what was written before
+	section := func(c []string) {
+		if b.Len() > 0 && len(c) == 0 {
+			b.WriteString("\n")
+		}
+		comments(b.Len() == 0, c)
+	}
+	if d := df.Default; d != nil {
+		section(d.Comments)
+		b.WriteString(d.String() + ";")
+		trailing(d.Trailing)
+	}
+	for i, r := range df.Rules {
+		if sortDeps {
+			r = r.sorted()
+		}
+		if i == 0 {
+			section(r.Comments)
+		} else {
+			comments(false, r.Comments)
+		}
+		head := r.head()
+		b.WriteString(head + strings.Repeat(" ", width-len(head)) + " <-")
+		if body := r.body(); body != "" {
+			b.WriteString(" " + strings.TrimPrefix(body, " "))
+		}
+		b.WriteString(";")
+		trailing(r.Trailing)
+	}
+	comments(false, df.Comments)
+	return b.String()
+}
+
+// sorted returns a copy of the rule with sorted deps
+func (r *Rule) sorted() *Rule {
+	c := *r
+	c.Deps = append([]string{}, r.Deps...)
+	c.OrderOnly = append([]string{}, r.OrderOnly...)
+	sort.Strings(c.Deps)
+	sort.Strings(c.OrderOnly)
+	return &c
+}
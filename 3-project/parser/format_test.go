@@ -0,0 +1,110 @@
+package parser
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	s := `phony all<-r;r <- d3 d1|dir;
+d1<-   d2 ;clean <- ;`
+
+	dFile, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `phony all <- r;
+r         <- d3 d1 | dir;
+d1        <- d2;
+clean     <-;
+`
+	if res := dFile.Format(false); res != expected {
+		t.Errorf("Wrong format. expected=\n%s\ngot=\n%s", expected, res)
+	}
+
+	sorted := dFile.Format(true)
+	expected = `phony all <- r;
+r         <- d1 d3 | dir;
+d1        <- d2;
+clean     <-;
+`
+	if sorted != expected {
+		t.Errorf("Wrong sorted format. expected=\n%s\ngot=\n%s", expected, sorted)
+	}
+	if dFile.Rules[1].Deps[0] != "d3" {
+		t.Error("Sorting changed the parsed rule")
+	}
+
+	// Formatting is stable
+	again, err := Parse(sorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := again.Format(true); res != sorted {
+		t.Errorf("Format isn't stable. got=\n%s", res)
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	s := `# Root
+root <- d1; # trailing
+d1 <- /* inside */ d2;
+// Leaf
+d2 <-;
+# The end`
+
+	dFile, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# Root
+root <- d1; # trailing
+
+/* inside */
+d1   <- d2;
+
+// Leaf
+d2   <-;
+
+# The end
+`
+	if res := dFile.Format(false); res != expected {
+		t.Errorf("Wrong format. expected=\n%s\ngot=\n%s", expected, res)
+	}
+}
+
+func TestFormatPools(t *testing.T) {
+	s := `pool link=2;pool cc = 8; // compiles
+app [pool=link] <- main.o;main.o [pool=cc] <- main.c;`
+
+	dFile, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `pool link = 2;
+pool cc = 8; // compiles
+
+app [pool=link]  <- main.o;
+main.o [pool=cc] <- main.c;
+`
+	if res := dFile.Format(false); res != expected {
+		t.Errorf("Wrong format. expected=\n%s\ngot=\n%s", expected, res)
+	}
+}
+
+func TestFormatDefault(t *testing.T) {
+	for s, expected := range map[string]string{
+		"default app;app <- a;":                     "default app;\n\napp <- a;\n",
+		"pool p=1;default app tests;app <- a;":      "pool p = 1;\n\ndefault app tests;\n\napp <- a;\n",
+		"pool p=1;\n# goals\ndefault app;app <- a;": "pool p = 1;\n\n# goals\ndefault app;\n\napp <- a;\n",
+		"default app;\n# rules\napp <- a;":          "default app;\n\n# rules\napp <- a;\n",
+	} {
+		dFile, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := dFile.Format(false); res != expected {
+			t.Errorf("Wrong format of %q. expected=\n%s\ngot=\n%s", s, expected, res)
+		}
+	}
+}
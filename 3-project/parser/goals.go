@@ -0,0 +1,31 @@
+package parser
+
+// Roots returns the targets that no rule depends
+// on, in the order of their rules. A file with a
+// single root is a tree, otherwise it's a forest.
+func (df *DepFile) Roots() []string {
+	needed := make(map[string]bool)
+	for _, r := range df.Rules {
+		for _, dep := range r.allDeps() {
+			needed[dep] = true
+		}
+	}
+
+	var roots []string
+	for _, r := range df.Rules {
+		if !needed[r.Object] && !contains(roots, r.Object) {
+			roots = append(roots, r.Object)
+		}
+	}
+	return roots
+}
+
+// Goals returns what's built when nothing is asked
+// for: the declared default goals or, without a
+// default declaration, every root
+func (df *DepFile) Goals() []string {
+	if df.Default != nil {
+		return df.Default.Goals
+	}
+	return df.Roots()
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	identRe = regexp.MustCompile(`^(` + identPattern + `)$`)
+	extRe   = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*$`)
+)
+
+// Clash is an error for different names
+// that are sanitized into the same one
+type Clash struct {
+	Ident string
+	Names [2]string
+}
+
+func (e *Clash) Error() string {
+	return fmt.Sprintf("%q and %q are both named %q", e.Names[0], e.Names[1], e.Ident)
+}
+
+// Idents sanitizes names, making sure that
+// different ones don't end up the same
+type Idents struct {
+	idents map[string]string // By name
+	names  map[string]string // By ident
+}
+
+func NewIdents() *Idents {
+	return &Idents{
+		idents: make(map[string]string),
+		names:  make(map[string]string),
+	}
+}
+
+// Get returns the ident of name
+func (ids *Idents) Get(name string) (string, error) {
+	if ident, ok := ids.idents[name]; ok {
+		return ident, nil
+	}
+	ident := SanitizeIdent(name)
+	if other, ok := ids.names[ident]; ok {
+		return "", &Clash{Ident: ident, Names: [2]string{other, name}}
+	}
+	ids.idents[name], ids.names[ident] = ident, name
+	return ident, nil
+}
+
+// Taken tells if some name got the ident
+func (ids *Idents) Taken(ident string) bool {
+	_, ok := ids.names[ident]
+	return ok
+}
+
+// IsIdent tells if s can be a target or a dep
+func IsIdent(s string) bool {
+	return identRe.MatchString(s)
+}
+
+// SanitizeIdent turns s into a valid target or dep
+// name, e.g. "net/http" into "net_http". A final
+// extension is kept if it's valid, so "yaml.v3"
+// stays the same. Different names may clash.
+func SanitizeIdent(s string) string {
+	if IsIdent(s) {
+		return s
+	}
+	var ext string
+	if i := strings.LastIndex(s, "."); i > 0 && extRe.MatchString(s[i+1:]) {
+		s, ext = s[:i], s[i:]
+	}
+
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || '0' <= b[0] && b[0] <= '9' {
+		b = append([]byte{'_'}, b...)
+	}
+	return string(b) + ext
+}
@@ -0,0 +1,44 @@
+package parser
+
+import "testing"
+
+func TestSanitizeIdent(t *testing.T) {
+	for s, expected := range map[string]string{
+		"main.o":                              "main.o",
+		"net/http":                            "net_http",
+		"github.com/alecthomas/participle/v2": "github_com_alecthomas_participle_v2",
+		"gopkg.in/yaml.v3":                    "gopkg_in_yaml.v3",
+		"lib-foo.a":                           "lib_foo.a",
+		"2fa":                                 "_2fa",
+		"a.tar.gz":                            "a_tar.gz",
+		"":                                    "_",
+	} {
+		res := SanitizeIdent(s)
+		if res != expected {
+			t.Errorf("Wrong ident of %q. expected=%q, got=%q", s, expected, res)
+		}
+		if !IsIdent(res) {
+			t.Errorf("%q isn't an ident", res)
+		}
+		if _, err := Parse(res + " <- ;"); err != nil {
+			t.Errorf("%q can't be parsed: %v", res, err)
+		}
+	}
+}
+
+func TestIdents(t *testing.T) {
+	ids := NewIdents()
+	if id, err := ids.Get("src/main.c"); err != nil || id != "src_main.c" {
+		t.Errorf("Wrong ident of src/main.c. got=%q, %v", id, err)
+	}
+	if id, err := ids.Get("src/main.c"); err != nil || id != "src_main.c" {
+		t.Errorf("The same name should get the same ident. got=%q, %v", id, err)
+	}
+	if !ids.Taken("src_main.c") || ids.Taken("main.c") {
+		t.Error("Wrong taken idents")
+	}
+	_, err := ids.Get("src-main.c")
+	if clash, ok := err.(*Clash); !ok || clash.Names != [2]string{"src/main.c", "src-main.c"} {
+		t.Errorf("Expecting a clash with src/main.c. got=%v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Issue is something wrong with a rule
+type Issue struct {
+	Pos lexer.Position
+	Msg string
+}
+
+func (i *Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Pos, i.Msg)
+}
+
+// Lint looks for duplicate deps in a rule, targets
+// depending on themselves, default goals without a
+// rule, rules that the default goals don't need (if
+// declared) and targets whose names only differ
+// in case from existing leaf files (i.e. the same
+// file on case-insensitive file systems). exists
+// tells if a leaf file exists, every leaf is checked
+// if it's nil.
+func (df *DepFile) Lint(exists func(string) bool) []*Issue {
+	var issues []*Issue
+	report := func(r *Rule, format string, args ...any) {
+		issues = append(issues, &Issue{Pos: r.Pos, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	rules := make(map[string]*Rule)
+	for _, r := range df.Rules {
+		if _, ok := rules[r.Object]; !ok {
+			rules[r.Object] = r
+		}
+	}
+
+	for _, r := range df.Rules {
+		seen := make(map[string]bool)
+		for _, dep := range r.allDeps() {
+			if dep == r.Object {
+				report(r, "%q depends on itself", r.Object)
+			}
+			if seen[dep] {
+				report(r, "%q depends on %q more than once", r.Object, dep)
+			}
+			seen[dep] = true
+		}
+	}
+
+	if d := df.Default; d != nil {
+		used := make(map[string]bool)
+		var visit func(f string)
+		visit = func(f string) {
+			if used[f] {
+				return
+			}
+			used[f] = true
+			if r, ok := rules[f]; ok {
+				for _, dep := range r.allDeps() {
+					visit(dep)
+				}
+			}
+		}
+		for _, goal := range d.Goals {
+			if _, ok := rules[goal]; !ok {
+				issues = append(issues, &Issue{Pos: d.Pos, Msg: fmt.Sprintf("default goal %q has no rule", goal)})
+			}
+			visit(goal)
+		}
+		for _, r := range df.Rules {
+			if !used[r.Object] {
+				report(r, "rule of %q isn't needed by the default goals", r.Object)
+			}
+		}
+	}
+
+	// Leafs by their lower case name
+	leafs := make(map[string][]string)
+	for _, r := range df.Rules {
+		for _, dep := range r.allDeps() {
+			if _, ok := rules[dep]; ok {
+				continue
+			}
+			lower := strings.ToLower(dep)
+			if !contains(leafs[lower], dep) && (exists == nil || exists(dep)) {
+				leafs[lower] = append(leafs[lower], dep)
+			}
+		}
+	}
+	for _, r := range df.Rules {
+		for _, leaf := range leafs[strings.ToLower(r.Object)] {
+			report(r, "target %q clashes with leaf file %q", r.Object, leaf)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Pos.Offset < issues[j].Pos.Offset
+	})
+	return issues
+}
+
+// allDeps returns the deps, order-only ones included
+func (r *Rule) allDeps() []string {
+	return append(append([]string{}, r.Deps...), r.OrderOnly...)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
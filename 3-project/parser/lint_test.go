@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	s := `default root x;
+root <- a b a;
+a <- a c;
+b <- C c;
+unused <- c;
+C <- d;`
+
+	dFile, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists := func(f string) bool { return f == "c" }
+	issues := dFile.Lint(exists)
+	expected := []string{
+		`1:1: default goal "x" has no rule`,
+		`2:1: "root" depends on "a" more than once`,
+		`3:1: "a" depends on itself`,
+		`5:1: rule of "unused" isn't needed by the default goals`,
+		`6:1: target "C" clashes with leaf file "c"`,
+	}
+	if len(issues) != len(expected) {
+		var got []string
+		for _, i := range issues {
+			got = append(got, i.String())
+		}
+		t.Fatalf("Expecting %d issues. got=\n%s", len(expected), strings.Join(got, "\n"))
+	}
+	for i, issue := range issues {
+		if issue.String() != expected[i] {
+			t.Errorf("Wrong issue. expected=%s, got=%s", expected[i], issue)
+		}
+	}
+
+	// Only existing leafs clash
+	if issues := dFile.Lint(func(string) bool { return false }); len(issues) != 4 {
+		t.Errorf("Expecting 4 issues when no leaf exists. got=%d", len(issues))
+	}
+}
+
+func TestLintForest(t *testing.T) {
+	// Without a default, every root is a goal
+	dFile, err := Parse("app <- a;\ntests <- app t;\ndocs <- d;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := dFile.Lint(nil); len(issues) != 0 {
+		t.Errorf("Expecting no issues. got=%v", issues)
+	}
+}
@@ -1,33 +1,110 @@
 package parser
 
 import (
+	"fmt"
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
 	"os"
 	"strings"
 )
 
+// identPattern matches the names of targets and deps
+const identPattern = `[a-zA-Z_][a-zA-Z_0-9]*([.][a-zA-Z][a-zA-Z0-9]*)?`
+
 var (
-	dfParser *participle.Parser[DepFile] = participle.MustBuild[DepFile](participle.Lexer(dfLexer))
-	dfLexer                              = lexer.MustSimple([]lexer.SimpleRule{
-		{"whitespace", `\s+`},
-		{"Ident", `[a-zA-Z_][a-zA-Z_0-9]*([.][a-zA-Z][a-zA-Z0-9]*)?`},
-		{"Punct", `<-`},
-		{"EOL", `[;]`},
+	dfParser *participle.Parser[DepFile] = participle.MustBuild[DepFile](
+		participle.Lexer(dfLexer),
+		participle.Elide("Comment"),
+	)
+	dfLexer = lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "whitespace", Pattern: `\s+`},
+		{Name: "Comment", Pattern: `#[^\n]*|//[^\n]*|/\*(?s:.)*?\*/`},
+		{Name: "Ident", Pattern: identPattern},
+		{Name: "Value", Pattern: `[0-9][a-zA-Z0-9.]*`},
+		{Name: "Punct", Pattern: `<-|[|,=\[\]]`},
+		{Name: "EOL", Pattern: `[;]`},
 	})
 )
 
 type DepFile struct {
-	Rules []*Rule `(@@)+ `
+	Pools    []*Pool  `parser:"((?= 'pool' Ident '=') @@)*"`
+	Default  *Default `parser:"((?= 'default' Ident) @@)?"`
+	Rules    []*Rule  `parser:"(@@)+"`
+	Comments []string // After the last rule
+}
+
+// Default declares the goals built when none
+// is asked for, e.g. "default app tests;"
+type Default struct {
+	Pos      lexer.Position
+	Goals    []string `parser:"'default' @Ident+ ';'"`
+	EndPos   lexer.Position
+	Comments []string
+	Trailing string
+}
+
+func (d *Default) String() string {
+	return "default " + strings.Join(d.Goals, " ")
+}
+
+// Pool limits how many of its targets are
+// built at the same time, e.g. "pool link = 2;"
+type Pool struct {
+	Pos      lexer.Position
+	Name     string `parser:"'pool' @Ident '='"`
+	Depth    int    `parser:"@Value ';'"`
+	EndPos   lexer.Position
+	Comments []string
+	Trailing string
 }
 
+func (p *Pool) String() string {
+	return fmt.Sprintf("pool %s = %d", p.Name, p.Depth)
+}
+
+// Rule is a target with its dependencies. A phony target
+// (e.g. test, clean) isn't a file, so it's always built.
+// Order-only deps, the ones after "|", must be built
+// first but never trigger a rebuild of the target.
+// Attrs configure the build of the target, e.g.
+// "r [timeout=30s, retries=2] <- d;".
+// Comments are the ones right before the rule, or in
+// the middle of it, and Trailing the one on the same
+// line, after the ";".
 type Rule struct {
-	Object string   `@Ident "<-"`
-	Deps   []string `@Ident+ ";"`
+	Pos       lexer.Position
+	Phony     bool     `parser:"(@'phony' (?= Ident))?"`
+	Object    string   `parser:"@Ident"`
+	Attrs     []*Attr  `parser:"('[' @@ (',' @@)* ']')? '<-'"`
+	Deps      []string `parser:"@Ident*"`
+	OrderOnly []string `parser:"('|' @Ident+)? ';'"`
+	EndPos    lexer.Position
+	Comments  []string
+	Trailing  string
+}
+
+// Attr is a key with an optional value
+type Attr struct {
+	Pos   lexer.Position
+	Key   string `parser:"@Ident"`
+	Value string `parser:"('=' @(Ident | Value))?"`
+}
+
+func (a *Attr) String() string {
+	if a.Value == "" {
+		return a.Key
+	}
+	return a.Key + "=" + a.Value
 }
 
 func (df *DepFile) String() string {
 	var res string
+	for _, p := range df.Pools {
+		res += p.String() + "\n"
+	}
+	if df.Default != nil {
+		res += df.Default.String() + "\n"
+	}
 	for _, r := range df.Rules {
 		res += r.String() + "\n"
 	}
@@ -35,25 +112,134 @@ func (df *DepFile) String() string {
 }
 
 func (r *Rule) String() string {
-	var deps string
-	for _, d := range r.Deps {
-		deps += d + " "
+	return r.head() + " <- " + r.body()
+}
 
+// Attr returns the value of the attribute
+// with the given key, if the rule has it
+func (r *Rule) Attr(key string) (string, bool) {
+	for _, a := range r.Attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
 	}
-	return r.Object + " <- " + strings.Join(r.Deps, " ")
+	return "", false
 }
 
-func Parse(s string) (*DepFile, error) {
-	return dfParser.ParseString("", s)
+// IsPhony tells if the rule is phony, either
+// by its keyword or by its attribute
+func (r *Rule) IsPhony() bool {
+	_, ok := r.Attr("phony")
+	return r.Phony || ok
+}
 
+// head returns what's before the arrow
+func (r *Rule) head() string {
+	res := r.Object
+	if r.Phony {
+		res = "phony " + res
+	}
+	if len(r.Attrs) > 0 {
+		attrs := make([]string, len(r.Attrs))
+		for i, a := range r.Attrs {
+			attrs[i] = a.String()
+		}
+		res += " [" + strings.Join(attrs, ", ") + "]"
+	}
+	return res
 }
 
+// body returns the deps after the arrow
+func (r *Rule) body() string {
+	res := strings.Join(r.Deps, " ")
+	if len(r.OrderOnly) > 0 {
+		res += " | " + strings.Join(r.OrderOnly, " ")
+	}
+	return res
+}
+
+func Parse(s string) (*DepFile, error) {
+	return parse("", s)
+}
+
+// ParseFile reads a dependency file
+// in any format, see DetectFormat
 func ParseFile(file string) (*DepFile, error) {
-	r, err := os.Open(file)
+	b, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
-	ast, err := dfParser.Parse("", r)
-	r.Close()
-	return ast, err
+	if DetectFormat(file, b) != DF {
+		return decode(file, b)
+	}
+	return parse(file, string(b))
+}
+
+func parse(filename, s string) (*DepFile, error) {
+	ast, err := dfParser.ParseString(filename, s)
+	if err != nil {
+		return ast, err
+	}
+	return ast, ast.attach(filename, s)
+}
+
+// commented is a pool, a rule or the default
+// declaration, which can have comments attached to it
+type commented struct {
+	pos, end lexer.Position
+	comments *[]string
+	trailing *string
+}
+
+// attach gives the comments, which the grammar
+// ignores, to the pools and rules they're next to.
+// The ones in the middle of a rule go before it,
+// so that formatting the file doesn't lose them.
+func (df *DepFile) attach(filename, s string) error {
+	lex, err := dfLexer.LexString(filename, s)
+	if err != nil {
+		return err
+	}
+	tokens, err := lexer.ConsumeAll(lex)
+	if err != nil {
+		return err
+	}
+	symbols := dfLexer.Symbols()
+
+	var items []commented
+	for _, p := range df.Pools {
+		items = append(items, commented{p.Pos, p.EndPos, &p.Comments, &p.Trailing})
+	}
+	if d := df.Default; d != nil {
+		items = append(items, commented{d.Pos, d.EndPos, &d.Comments, &d.Trailing})
+	}
+	for _, r := range df.Rules {
+		items = append(items, commented{r.Pos, r.EndPos, &r.Comments, &r.Trailing})
+	}
+
+	i := 0               // First item that hasn't ended before the comment
+	var last lexer.Token // Last token that isn't a comment
+	for _, t := range tokens {
+		switch t.Type {
+		case symbols["whitespace"]:
+			continue
+		case symbols["Comment"]:
+		default:
+			last = t
+			continue
+		}
+		for i < len(items) && items[i].end.Offset <= t.Pos.Offset {
+			i++
+		}
+		ended := last.Value == ";" && last.Pos.Line == t.Pos.Line
+		switch {
+		case i > 0 && ended && *items[i-1].trailing == "":
+			*items[i-1].trailing = t.Value
+		case i == len(items):
+			df.Comments = append(df.Comments, t.Value)
+		default:
+			*items[i].comments = append(*items[i].comments, t.Value)
+		}
+	}
+	return nil
 }
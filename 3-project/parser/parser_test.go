@@ -1,6 +1,9 @@
 package parser
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestBasic(t *testing.T) {
 	s := "root <- dep1 dep2 dep3;"
@@ -136,3 +139,200 @@ dep2.h <- dep3.o;
 		t.Error("Second head is not dep2.h.")
 	}
 }
+
+func TestPhonyOrderOnly(t *testing.T) {
+	s := `phony test <- root | dir;
+root <- dep1 dep2 | dir;
+phony <- dep1;
+phony clean <- ;`
+	res, err := Parse(s)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(res.Rules) != 4 {
+		t.Error("Failed to parse 4 rules")
+		return
+	}
+	if !res.Rules[0].Phony || res.Rules[0].Object != "test" {
+		t.Error("First head is not phony test.")
+	}
+	if len(res.Rules[0].Deps) != 1 || len(res.Rules[0].OrderOnly) != 1 {
+		t.Error("Expected 1 dep and 1 order-only dep in first rule.")
+	}
+	if res.Rules[1].Phony {
+		t.Error("Second head shouldn't be phony.")
+	}
+	if len(res.Rules[1].Deps) != 2 || res.Rules[1].OrderOnly[0] != "dir" {
+		t.Error("Expected deps [dep1 dep2] and order-only [dir] in second rule.")
+	}
+	if res.Rules[2].Phony || res.Rules[2].Object != "phony" {
+		t.Error("Third head is not a target named phony.")
+	}
+	if !res.Rules[3].Phony || len(res.Rules[3].Deps) != 0 {
+		t.Error("Fourth head is not phony clean without deps.")
+	}
+	if got := res.Rules[0].String(); got != "phony test <- root | dir" {
+		t.Error("Unexpected rule string", got)
+	}
+}
+
+func TestComments(t *testing.T) {
+	s := `# The whole project
+// built by default
+root <- d1 /* inside */ d2; # trailing
+/* A block
+   comment */
+d1 <- d2; // another
+d2 <- ; # last
+# The end`
+
+	res, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rules) != 3 {
+		t.Fatalf("Failed to parse 3 rules. got=%d", len(res.Rules))
+	}
+	root, d1, d2 := res.Rules[0], res.Rules[1], res.Rules[2]
+	if len(root.Deps) != 2 {
+		t.Errorf("Expected 2 deps, found %d", len(root.Deps))
+	}
+	if len(root.Comments) != 3 || root.Comments[0] != "# The whole project" ||
+		root.Comments[1] != "// built by default" || root.Comments[2] != "/* inside */" {
+		t.Errorf("Wrong comments of root. got=%q", root.Comments)
+	}
+	if root.Pos.Line != 3 {
+		t.Errorf("Rule should start at line 3. got=%d", root.Pos.Line)
+	}
+	if root.Trailing != "# trailing" {
+		t.Errorf("Wrong trailing comment of root. got=%q", root.Trailing)
+	}
+	if len(d1.Comments) != 1 || d1.Comments[0] != "/* A block\n   comment */" || d1.Trailing != "// another" {
+		t.Errorf("Wrong comments of d1. got=%q, %q", d1.Comments, d1.Trailing)
+	}
+	if len(d2.Comments) != 0 || d2.Trailing != "# last" {
+		t.Errorf("Wrong comments of d2. got=%q, %q", d2.Comments, d2.Trailing)
+	}
+	if len(res.Comments) != 1 || res.Comments[0] != "# The end" {
+		t.Errorf("Wrong comments at the end. got=%q", res.Comments)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	s := `app [timeout=1m30s, retries=2, pool=link, phony] <- main.o | dir;
+main.o [] <- main.c;`
+
+	_, err := Parse(s)
+	if err == nil {
+		t.Error("Expected an error with empty attributes")
+	}
+
+	s = `app [timeout=1m30s, retries=2, pool=link, phony] <- main.o | dir;`
+	res, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := res.Rules[0]
+	if r.Object != "app" || r.Phony {
+		t.Errorf("Wrong target. got=%q, phony=%t", r.Object, r.Phony)
+	}
+	expected := []Attr{
+		{Key: "timeout", Value: "1m30s"},
+		{Key: "retries", Value: "2"},
+		{Key: "pool", Value: "link"},
+		{Key: "phony"},
+	}
+	if len(r.Attrs) != len(expected) {
+		t.Fatalf("Expected %d attributes, found %d", len(expected), len(r.Attrs))
+	}
+	for i, a := range r.Attrs {
+		if a.Key != expected[i].Key || a.Value != expected[i].Value {
+			t.Errorf("Wrong attribute. expected=%s, got=%s", &expected[i], a)
+		}
+	}
+	if r.String() != "app [timeout=1m30s, retries=2, pool=link, phony] <- main.o | dir" {
+		t.Errorf("Wrong string. got=%q", r.String())
+	}
+	if v, ok := r.Attr("pool"); !ok || v != "link" {
+		t.Errorf("Wrong pool attribute. got=%q", v)
+	}
+	if _, ok := r.Attr("color"); ok {
+		t.Error("Unexpected color attribute")
+	}
+	if !r.IsPhony() {
+		t.Error("The phony attribute should make the rule phony")
+	}
+}
+
+func TestPools(t *testing.T) {
+	s := `# Memory heavy
+pool link = 2; # at most
+pool cc = 8;
+pool <- app;
+app [pool=link] <- main.o;`
+
+	res, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Pools) != 2 {
+		t.Fatalf("Failed to parse 2 pools. got=%d", len(res.Pools))
+	}
+	link := res.Pools[0]
+	if link.Name != "link" || link.Depth != 2 || res.Pools[1].Name != "cc" || res.Pools[1].Depth != 8 {
+		t.Errorf("Wrong pools. got=%s, %s", link, res.Pools[1])
+	}
+	if len(link.Comments) != 1 || link.Trailing != "# at most" {
+		t.Errorf("Wrong comments of link. got=%q, %q", link.Comments, link.Trailing)
+	}
+	// Still a valid target name
+	if len(res.Rules) != 2 || res.Rules[0].Object != "pool" {
+		t.Errorf("Failed to parse the rule of pool")
+	}
+
+	if _, err := Parse("pool link = 2;"); err == nil {
+		t.Error("Expected an error without rules")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	s := `pool link = 2;
+# What's built by default
+default app docs; # not tests
+app <- a;
+tests <- app t;
+docs <- d;
+default <- x;`
+
+	res, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := res.Default
+	if d == nil || d.String() != "default app docs" {
+		t.Fatalf("Failed to parse the default. got=%v", d)
+	}
+	if len(d.Comments) != 1 || d.Trailing != "# not tests" {
+		t.Errorf("Wrong comments of the default. got=%q, %q", d.Comments, d.Trailing)
+	}
+	// Still a valid target name
+	if len(res.Rules) != 4 || res.Rules[3].Object != "default" {
+		t.Errorf("Failed to parse the rule of default")
+	}
+
+	if goals := fmt.Sprint(res.Goals()); goals != "[app docs]" {
+		t.Errorf("Wrong goals. got=%s", goals)
+	}
+	if roots := fmt.Sprint(res.Roots()); roots != "[tests docs default]" {
+		t.Errorf("Wrong roots. got=%s", roots)
+	}
+	res.Default = nil
+	if goals := fmt.Sprint(res.Goals()); goals != "[tests docs default]" {
+		t.Errorf("Without a default, the roots are the goals. got=%s", goals)
+	}
+
+	if _, err := Parse("default a;\ndefault b;\na <- b;"); err == nil {
+		t.Error("Expected an error with two defaults")
+	}
+}
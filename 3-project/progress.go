@@ -0,0 +1,185 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	redrawEvery = 100 * time.Millisecond
+	maxRunning  = 5 // Running targets shown
+	barWidth    = 30
+)
+
+// isTerminal tells if f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progress shows the state of a build,
+// redrawing itself on every tick
+type progress struct {
+	w         io.Writer
+	nodes     []string
+	estimates map[string]time.Duration // Of previous builds
+	workers   int
+
+	running  map[string]time.Time // When they started
+	finished map[string]bool      // Whether they failed
+	failed   int
+	drawn    int // Lines drawn last time
+}
+
+func newProgress(w io.Writer, nodes []string, estimates map[string]time.Duration) *progress {
+	return &progress{
+		w:         w,
+		nodes:     nodes,
+		estimates: estimates,
+		workers:   runtime.NumCPU(),
+		running:   make(map[string]time.Time),
+		finished:  make(map[string]bool),
+	}
+}
+
+// show is the sink that keeps the display updated
+func (p *progress) show(evCh <-chan *builder.Event) {
+	ticker := time.NewTicker(redrawEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-evCh:
+			if !ok {
+				p.draw(time.Now())
+				return
+			}
+			p.update(ev)
+		case now := <-ticker.C:
+			p.draw(now)
+		}
+	}
+}
+
+func (p *progress) update(ev *builder.Event) {
+	switch ev.Type {
+	case builder.TargetStarted:
+		p.running[ev.Target] = ev.Time
+	case builder.TargetSkipped, builder.TargetBuilt:
+		delete(p.running, ev.Target)
+		p.finished[ev.Target] = false
+	case builder.TargetFailed:
+		delete(p.running, ev.Target)
+		p.finished[ev.Target] = true
+		p.failed++
+	}
+}
+
+// estimate returns how long target is expected to
+// take. Unknown targets take as long as the average.
+func (p *progress) estimate(target string) (time.Duration, bool) {
+	if d, ok := p.estimates[target]; ok {
+		return d, true
+	}
+	if len(p.estimates) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, d := range p.estimates {
+		total += d
+	}
+	return total / time.Duration(len(p.estimates)), true
+}
+
+// eta returns the time left, assuming the work
+// is spread over all workers. It's only known
+// if there's some history.
+func (p *progress) eta(now time.Time) (time.Duration, bool) {
+	var left time.Duration
+	for _, target := range p.nodes {
+		if _, ok := p.finished[target]; ok {
+			continue
+		}
+		d, ok := p.estimate(target)
+		if !ok {
+			return 0, false
+		}
+		if start, ok := p.running[target]; ok {
+			if d -= now.Sub(start); d < 0 {
+				d = 0
+			}
+		}
+		left += d
+	}
+	return left / time.Duration(p.workers), true
+}
+
+// render returns the lines describing the build
+func (p *progress) render(now time.Time) []string {
+	total := len(p.nodes)
+	done := len(p.finished) - p.failed
+	pending := total - len(p.finished) - len(p.running)
+
+	filled := barWidth
+	if total > 0 {
+		filled = barWidth * len(p.finished) / total
+	}
+	eta := "ETA --"
+	if d, ok := p.eta(now); ok {
+		eta = "ETA " + d.Round(100*time.Millisecond).String()
+	}
+
+	lines := []string{fmt.Sprintf(
+		"[%s%s] %d/%d done, %d running, %d pending, %d failed, %s",
+		strings.Repeat("#", filled), strings.Repeat(".", barWidth-filled),
+		done, total, len(p.running), pending, p.failed, eta,
+	)}
+
+	running := make([]string, 0, len(p.running))
+	for target := range p.running {
+		running = append(running, target)
+	}
+	// Longest running first
+	sort.Slice(running, func(i, j int) bool {
+		ti, tj := p.running[running[i]], p.running[running[j]]
+		if ti.Equal(tj) {
+			return running[i] < running[j]
+		}
+		return ti.Before(tj)
+	})
+	for i, target := range running {
+		if i == maxRunning {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(running)-maxRunning))
+			break
+		}
+		elapsed := now.Sub(p.running[target]).Round(100 * time.Millisecond)
+		lines = append(lines, fmt.Sprintf("  building %s (%s)", target, elapsed))
+	}
+	return lines
+}
+
+// draw replaces the previous lines with the current state
+func (p *progress) draw(now time.Time) {
+	var b strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", p.drawn) // Cursor up
+	}
+	lines := p.render(now)
+	for _, line := range lines {
+		b.WriteString("\033[2K" + line + "\n")
+	}
+	// Clears what's left from the last time
+	for i := len(lines); i < p.drawn; i++ {
+		b.WriteString("\033[2K\n")
+	}
+	if extra := p.drawn - len(lines); extra > 0 {
+		fmt.Fprintf(&b, "\033[%dA", extra)
+	}
+	p.drawn = len(lines)
+	io.WriteString(p.w, b.String())
+}
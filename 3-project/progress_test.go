@@ -0,0 +1,56 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressCounts(t *testing.T) {
+	now := time.Now()
+	p := newProgress(nil, []string{"a", "b", "c", "d", "e"}, nil)
+	p.workers = 1
+
+	p.update(&builder.Event{Type: builder.TargetSkipped, Target: "a"})
+	p.update(&builder.Event{Type: builder.TargetStarted, Target: "b", Time: now})
+	p.update(&builder.Event{Type: builder.TargetStarted, Target: "c", Time: now})
+	p.update(&builder.Event{Type: builder.TargetBuilt, Target: "c"})
+	p.update(&builder.Event{Type: builder.TargetStarted, Target: "d", Time: now})
+	p.update(&builder.Event{Type: builder.TargetFailed, Target: "d"})
+
+	lines := p.render(now)
+	expect := "2/5 done, 1 running, 1 pending, 1 failed, ETA --"
+	if !strings.HasSuffix(lines[0], expect) {
+		t.Errorf("Wrong summary. got=%q, expect suffix=%q", lines[0], expect)
+	}
+	if len(lines) != 2 || !strings.Contains(lines[1], "building b") {
+		t.Errorf("Expecting b to be shown as running. got=%q", lines)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	now := time.Now()
+	estimates := map[string]time.Duration{
+		"a": 2 * time.Second,
+		"b": 4 * time.Second,
+	}
+	p := newProgress(nil, []string{"a", "b", "c"}, estimates)
+	p.workers = 2
+
+	// c takes the average, 3s
+	if eta, ok := p.eta(now); !ok || eta != 9*time.Second/2 {
+		t.Errorf("Wrong ETA. got=%v, expect=4.5s", eta)
+	}
+
+	p.update(&builder.Event{Type: builder.TargetBuilt, Target: "b"})
+	p.update(&builder.Event{Type: builder.TargetStarted, Target: "a", Time: now})
+	if eta, _ := p.eta(now.Add(time.Second)); eta != 2*time.Second {
+		t.Errorf("Wrong ETA. got=%v, expect=2s", eta)
+	}
+
+	p = newProgress(nil, []string{"a"}, nil)
+	if _, ok := p.eta(now); ok {
+		t.Error("ETA without history shouldn't be known")
+	}
+}
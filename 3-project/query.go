@@ -0,0 +1,120 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"cpl_go_proj22/parser"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// query runs a question about the graph of a dependency
+// file, printing the resulting files one per line or as
+// a JSON array
+type query struct {
+	name       string
+	args       []string // Besides the location
+	transitive bool     // Whether -t makes sense
+	run        func(g *builder.Graph, args []string, transitive bool) ([]string, error)
+}
+
+var queries = []*query{
+	{
+		name: "deps", args: []string{"target"}, transitive: true,
+		run: func(g *builder.Graph, args []string, transitive bool) ([]string, error) {
+			return g.Deps(args[0], transitive)
+		},
+	},
+	{
+		name: "rdeps", args: []string{"file"}, transitive: true,
+		run: func(g *builder.Graph, args []string, transitive bool) ([]string, error) {
+			return g.RDeps(args[0], transitive)
+		},
+	},
+	{
+		name: "path", args: []string{"from", "to"},
+		run: func(g *builder.Graph, args []string, _ bool) ([]string, error) {
+			return g.Path(args[0], args[1])
+		},
+	},
+	{
+		name: "roots",
+		run: func(g *builder.Graph, _ []string, _ bool) ([]string, error) {
+			return g.Roots(), nil
+		},
+	},
+	{
+		name: "leaves",
+		run: func(g *builder.Graph, _ []string, _ bool) ([]string, error) {
+			return g.Leafs(), nil
+		},
+	},
+	{
+		name: "topo",
+		run: func(g *builder.Graph, _ []string, _ bool) ([]string, error) {
+			return g.Topo(), nil
+		},
+	},
+}
+
+func init() {
+	for _, q := range queries {
+		q := q
+		commands[q.name] = q.exec
+	}
+}
+
+func (q *query) usage() string {
+	usage := "project " + q.name
+	if q.transitive {
+		usage += " [-t]"
+	}
+	usage += " [-json] <location>"
+	for _, arg := range q.args {
+		usage += " <" + arg + ">"
+	}
+	return usage
+}
+
+func (q *query) exec(args []string) {
+	flags := flag.NewFlagSet(q.name, flag.ExitOnError)
+	var transitive *bool
+	if q.transitive {
+		transitive = flags.Bool("t", false, "Include the transitive ones")
+	} else {
+		transitive = new(bool)
+	}
+	asJSON := flags.Bool("json", false, "Print a JSON array")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1+len(q.args) {
+		fmt.Println("Usage: " + q.usage())
+		os.Exit(0)
+	}
+
+	dFile, err := parser.ParseFile(args[0])
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	files, err := q.run(builder.NewGraph(dFile), args[1:], *transitive)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		if files == nil {
+			files = []string{}
+		}
+		data, _ := json.Marshal(files)
+		fmt.Println(string(data))
+		return
+	}
+	if len(files) > 0 {
+		fmt.Println(strings.Join(files, "\n"))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"cpl_go_proj22/s3scan"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// s3Flags are the flags of the commands
+// that can keep the files in a bucket
+type s3Flags struct {
+	url      *string
+	endpoint *string
+	region   *string
+}
+
+func addS3Flags(flags *flag.FlagSet) *s3Flags {
+	return &s3Flags{
+		url:      flags.String("s3", "", "Keep the files in a bucket instead, given as s3://bucket/prefix (credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)"),
+		endpoint: flags.String("s3-endpoint", "", "URL of the S3-compatible store (AWS by default)"),
+		region:   flags.String("s3-region", "us-east-1", "Region of the bucket"),
+	}
+}
+
+// set tells if the files are in a bucket
+func (sf *s3Flags) set() bool {
+	return *sf.url != ""
+}
+
+// check rejects the flags of a files location
+// along with -s3, since they'd be about the
+// current directory instead of the bucket
+func (sf *s3Flags) check(flags *flag.FlagSet) error {
+	if !sf.set() {
+		return nil
+	}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "d" || f.Name == "wait" {
+			err = fmt.Errorf("-%s is about a files location, it can't go with -s3", f.Name)
+		}
+	})
+	return err
+}
+
+// historyPath returns where the history is kept:
+// with -s3, nowhere unless -history tells, since
+// the current directory isn't where the files are
+func (sf *s3Flags) historyPath(value, dir string) string {
+	if sf.set() && value == "" {
+		return ""
+	}
+	return historyPath(value, dir)
+}
+
+// scan returns the scan of the bucket
+func (sf *s3Flags) scan() (*s3scan.S3Scan, error) {
+	bucket, prefix, err := s3scan.ParseURL(*sf.url)
+	if err != nil {
+		return nil, err
+	}
+	return s3scan.New(s3scan.Config{
+		Endpoint:  *sf.endpoint,
+		Region:    *sf.region,
+		Bucket:    bucket,
+		Prefix:    prefix,
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	})
+}
@@ -0,0 +1,233 @@
+package s3scan
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata of the objects, sent as x-amz-meta-* headers.
+// Last-Modified only has seconds, which isn't enough
+// to tell if a target is newer than its deps.
+const (
+	MetaModTime = "X-Amz-Meta-Mtime"  // RFC 3339, with nanoseconds
+	MetaBuilds  = "X-Amz-Meta-Builds" // Times the object was built
+)
+
+// Config of a bucket of an S3-compatible object store
+type Config struct {
+	Endpoint  string // e.g. http://localhost:9000, AWS's if empty
+	Region    string // us-east-1 if empty
+	Bucket    string
+	Prefix    string // Of the keys, e.g. "ci/main/"
+	AccessKey string
+	SecretKey string
+	Client    *http.Client // With a 30s timeout if nil
+}
+
+// S3Scan is a CleanScan whose files are the objects
+// of a bucket: Status reads their metadata (HEAD),
+// Build uploads them (PUT) and Remove deletes them
+// (DELETE). Requests are signed with Signature V4
+// and objects are addressed by path, which every
+// S3-compatible store supports.
+type S3Scan struct {
+	base   *url.URL // Of the bucket
+	prefix string
+	creds  *credentials
+	client *http.Client
+	now    func() time.Time
+}
+
+type InvalidConfig struct {
+	msg string
+}
+
+func (e *InvalidConfig) Error() string {
+	return "invalid S3 config: " + e.msg
+}
+
+type MissingObject struct {
+	key string
+}
+
+func (e *MissingObject) Error() string {
+	return fmt.Sprintf("object %q doesn't exist", e.key)
+}
+
+// Unwrap makes errors.Is(err, fs.ErrNotExist) true
+func (e *MissingObject) Unwrap() error {
+	return fs.ErrNotExist
+}
+
+// RequestFailed is an unexpected response of the store
+type RequestFailed struct {
+	method, key string
+	status      int
+	body        string
+}
+
+func (e *RequestFailed) Error() string {
+	return fmt.Sprintf("%s %q failed with %d: %s", e.method, e.key, e.status, e.body)
+}
+
+// New returns a scan of the bucket, or an
+// error if the config isn't complete
+func New(c Config) (*S3Scan, error) {
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+	}
+	if c.Bucket == "" {
+		return nil, &InvalidConfig{msg: "missing bucket"}
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return nil, &InvalidConfig{msg: "missing credentials"}
+	}
+	base, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/") + "/" + c.Bucket + "/")
+	if err != nil {
+		return nil, &InvalidConfig{msg: err.Error()}
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, &InvalidConfig{msg: fmt.Sprintf("endpoint %q isn't an http(s) URL", c.Endpoint)}
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &S3Scan{
+		base:   base,
+		prefix: c.Prefix,
+		creds:  &credentials{accessKey: c.AccessKey, secretKey: c.SecretKey, region: c.Region},
+		client: c.Client,
+		now:    time.Now,
+	}, nil
+}
+
+// ParseURL splits "s3://bucket/prefix" into
+// the bucket and the prefix of the keys
+func ParseURL(s string) (bucket, prefix string, err error) {
+	if !strings.HasPrefix(s, "s3://") {
+		return "", "", &InvalidConfig{msg: fmt.Sprintf("%q doesn't start with s3://", s)}
+	}
+	bucket, prefix, _ = strings.Cut(strings.TrimPrefix(s, "s3://"), "/")
+	if bucket == "" {
+		return "", "", &InvalidConfig{msg: fmt.Sprintf("%q has no bucket", s)}
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// do sends a signed request for the object
+// of the file and reads the whole response
+func (s *S3Scan) do(method, filename string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	key := s.prefix + filename
+	u := *s.base
+	u.Path += key
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.creds.sign(req, body, s.now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return res, b, &MissingObject{key: key}
+	case res.StatusCode/100 != 2:
+		return res, b, &RequestFailed{method: method, key: key, status: res.StatusCode, body: string(b)}
+	}
+	return res, b, nil
+}
+
+// head returns the modification time of the
+// object of the file and how many times it
+// was built
+func (s *S3Scan) head(filename string) (time.Time, int, error) {
+	res, _, err := s.do(http.MethodHead, filename, nil, nil)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	builds, _ := strconv.Atoi(res.Header.Get(MetaBuilds))
+
+	// Objects that weren't uploaded by
+	// a build only have Last-Modified
+	if mtime := res.Header.Get(MetaModTime); mtime != "" {
+		t, err := time.Parse(time.RFC3339Nano, mtime)
+		return t, builds, err
+	}
+	t, err := http.ParseTime(res.Header.Get("Last-Modified"))
+	return t, builds, err
+}
+
+// Status returns the modification time of the object
+func (s *S3Scan) Status(filename string) (time.Time, error) {
+	t, _, err := s.head(filename)
+	return t, err
+}
+
+// hidden tells if the error is the one S3 replies to
+// the HEAD of a missing key without the s3:ListBucket
+// permission: 403 instead of 404, so that it doesn't
+// tell which keys exist
+func hidden(err error) bool {
+	rf, ok := err.(*RequestFailed)
+	return ok && rf.method == http.MethodHead && rf.status == http.StatusForbidden
+}
+
+// Build uploads the object, which tells
+// how many times it was built, like
+// utils.FileScan does. A forbidden HEAD
+// is taken as a missing object, since the
+// PUT fails anyway if it's really forbidden.
+func (s *S3Scan) Build(filename string) (time.Time, error) {
+	var n int
+	if _, builds, err := s.head(filename); err == nil {
+		n = builds + 1
+	} else if _, ok := err.(*MissingObject); !ok && !hidden(err) {
+		return time.Time{}, err
+	}
+
+	t := s.now().UTC()
+	header := http.Header{
+		MetaModTime: {t.Format(time.RFC3339Nano)},
+		MetaBuilds:  {strconv.Itoa(n)},
+	}
+	body := []byte(strconv.Itoa(n) + " times built.\n")
+	if _, _, err := s.do(http.MethodPut, filename, header, body); err != nil {
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// Remove deletes the object. Stores don't
+// tell if it existed, so it's checked first.
+func (s *S3Scan) Remove(filename string) error {
+	if _, _, err := s.head(filename); err != nil {
+		return err
+	}
+	_, _, err := s.do(http.MethodDelete, filename, nil, nil)
+	return err
+}
@@ -0,0 +1,139 @@
+package s3scan
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm   = "AWS4-HMAC-SHA256"
+	service     = "s3"
+	amzDate     = "20060102T150405Z"
+	scopeDate   = "20060102"
+	emptyDigest = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// credentials sign requests with AWS Signature Version 4
+type credentials struct {
+	accessKey, secretKey, region string
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode escapes everything but the unreserved
+// characters, and "/" too if it's a path
+func uriEncode(s string, path bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', path && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery sorts the query parameters by name
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// canonicalRequest returns the request in the form
+// that's signed, along with its signed headers:
+// the host and every header already set
+func canonicalRequest(req *http.Request, payload string) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, k := range names {
+		canonical.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		req.Method,
+		uriEncode(path, true),
+		canonicalQuery(req),
+		canonical.String(),
+		signed,
+		payload,
+	}, "\n"), signed
+}
+
+// signature returns the signature of the canonical
+// request, made at t, and the scope of the credential
+func (c *credentials) signature(canonical string, t time.Time) (string, string) {
+	scope := strings.Join([]string{t.Format(scopeDate), c.region, service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{algorithm, t.Format(amzDate), scope, digest([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), t.Format(scopeDate))
+	for _, s := range []string{c.region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	return hex.EncodeToString(hmacSHA256(key, toSign)), scope
+}
+
+// sign adds the date, the digest of the payload and the
+// authorization to the request. Headers set afterwards
+// aren't signed.
+func (c *credentials) sign(req *http.Request, payload []byte, t time.Time) {
+	t = t.UTC()
+	hash := emptyDigest
+	if len(payload) > 0 {
+		hash = digest(payload)
+	}
+	req.Header.Set("X-Amz-Date", t.Format(amzDate))
+	req.Header.Set("X-Amz-Content-Sha256", hash)
+
+	canonical, signed := canonicalRequest(req, hash)
+	sig, scope := c.signature(canonical, t)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, c.accessKey, scope, signed, sig,
+	))
+}
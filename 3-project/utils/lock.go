@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockFile is taken by a FileScan in its base
+// path, so two builds don't race on the same files
+const LockFile = ".build.lock"
+
+// Locked means that another build, maybe
+// of the same process, holds the lock
+type Locked struct {
+	path string
+	pid  int
+}
+
+func (e *Locked) Error() string {
+	return fmt.Sprintf("%q is locked by the build of process %d", e.path, e.pid)
+}
+
+// held is a lock file taken by this process
+type held struct {
+	path string
+	f    *os.File // Kept open while held, if the lock needs it
+}
+
+// parsePID reads the PID held by a lock file
+func parsePID(b []byte) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// ours tells if the lock file still holds our PID
+func (h *held) ours() error {
+	b, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+	if pid, err := parsePID(b); err != nil || pid != os.Getpid() {
+		return fmt.Errorf("%q isn't locked by this process anymore", h.path)
+	}
+	return nil
+}
+
+// lockPath returns the lock file of the base path
+func lockPath(basePath string) string {
+	return filepath.Join(basePath, LockFile)
+}
@@ -0,0 +1,69 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// lock takes an exclusive flock of the lock file and
+// writes our PID in it, for Locked to tell. The kernel
+// releases the flock of a process that's gone, so a
+// stale lock file is simply taken over, with no race.
+// It's only advisory: nothing stops others from
+// touching the files.
+func lock(path string) (*held, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			b, _ := io.ReadAll(f)
+			pid, _ := parsePID(b)
+			f.Close()
+			return nil, &Locked{path: path, pid: pid}
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		// The holder removes the file before letting go
+		// of the flock, so ours may be of a removed file
+		opened, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err != nil || !os.SameFile(opened, current) {
+			f.Close()
+			continue
+		}
+
+		if err = f.Truncate(0); err == nil {
+			_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		}
+		if err != nil {
+			os.Remove(path)
+			f.Close()
+			return nil, err
+		}
+		return &held{path: path, f: f}, nil
+	}
+}
+
+// release removes the lock file, if it's still
+// ours, and only then lets go of the flock
+func (h *held) release() error {
+	defer h.f.Close()
+	if err := h.ours(); err != nil {
+		return err
+	}
+	return os.Remove(h.path)
+}
@@ -0,0 +1,65 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+// lock creates the lock file holding our PID, where
+// flock isn't available. A lock whose process is gone
+// is stale, so it's taken over, but only if it still
+// holds the PID that was read: a takeover racing with
+// another one doesn't remove the lock it just took.
+// It's only advisory: nothing stops others from
+// touching the files.
+func lock(path string) (*held, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &held{path: path}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		b, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Just released
+		}
+		if err != nil {
+			return nil, err
+		}
+		// A broken lock is stale, its
+		// build died while writing it
+		pid, err := parsePID(b)
+		if err == nil && alive(pid) {
+			return nil, &Locked{path: path, pid: pid}
+		}
+		if again, err := os.ReadFile(path); err != nil || string(again) != string(b) {
+			continue // Taken over by someone else
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+}
+
+// release removes the lock file, if it's still ours
+func (h *held) release() error {
+	if err := h.ours(); err != nil {
+		return err
+	}
+	return os.Remove(h.path)
+}
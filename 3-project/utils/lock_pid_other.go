@@ -0,0 +1,16 @@
+//go:build !unix
+
+package utils
+
+import "os"
+
+// alive tells if the process exists. Elsewhere
+// than unix, finding it fails if it doesn't.
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,20 @@
+//go:build unix && !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// alive tells if the process exists by sending it
+// the null signal, which only checks permissions
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
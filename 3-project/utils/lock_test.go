@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+	scan, err := NewFileScan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, LockFile))
+	if string(b) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("The lock should hold the PID. got=%q", b)
+	}
+
+	// Even the same process can't take it twice
+	if _, err := NewFileScan(dir); err == nil {
+		t.Fatal("Expecting the base path to be locked")
+	} else if _, ok := err.(*Locked); !ok {
+		t.Errorf("Err isn't of type Locked: got=%v", err)
+	}
+
+	if err := scan.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFile)); err == nil {
+		t.Error("Close should remove the lock")
+	}
+	scan, err = NewFileScan(dir)
+	if err != nil {
+		t.Fatalf("Expecting the lock to be released: %v", err)
+	}
+	scan.Close()
+}
+
+func TestStaleLock(t *testing.T) {
+	// Beyond any PID, and broken
+	for _, content := range []string{"2147483646\n", "12ab"} {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, LockFile), []byte(content), 0644)
+
+		scan, err := NewFileScan(dir)
+		if err != nil {
+			t.Fatalf("Lock %q should be stale: %v", content, err)
+		}
+		scan.Close()
+	}
+}
+
+func TestLockTakenOver(t *testing.T) {
+	dir := t.TempDir()
+	scan, _ := NewFileScan(dir)
+	os.WriteFile(filepath.Join(dir, LockFile), []byte("2147483646\n"), 0644)
+	if err := scan.Close(); err == nil {
+		t.Error("Close shouldn't remove a lock that isn't ours")
+	}
+}
+
+func TestStaleLockRace(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, LockFile), []byte("2147483646\n"), 0644)
+
+		scans := make(chan *FileScan)
+		for j := 0; j < 8; j++ {
+			go func() {
+				scan, err := NewFileScan(dir)
+				if _, ok := err.(*Locked); err != nil && !ok {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				scans <- scan
+			}()
+		}
+		var taken []*FileScan
+		for j := 0; j < 8; j++ {
+			if scan := <-scans; scan != nil {
+				taken = append(taken, scan)
+			}
+		}
+		if len(taken) != 1 {
+			t.Fatalf("Expecting a single takeover of the stale lock. got=%d", len(taken))
+		}
+		if err := taken[0].Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReadOnlyScan(t *testing.T) {
+	dir := t.TempDir()
+	scan, _ := NewFileScan(dir)
+	defer scan.Close()
+	scan.Build("a")
+
+	// Along with the build holding the lock
+	ro, err := NewReadOnlyScan(dir)
+	if err != nil {
+		t.Fatalf("A read-only scan shouldn't need the lock: %v", err)
+	}
+	if _, err := ro.Status("a"); err != nil {
+		t.Errorf("Expecting the status of a: %v", err)
+	}
+	if _, err := ro.Build("b"); err == nil {
+		t.Error("A read-only scan shouldn't build")
+	} else if _, ok := err.(*ReadOnly); !ok {
+		t.Errorf("Err isn't of type ReadOnly: got=%v", err)
+	}
+	if err := ro.Remove("a"); err == nil {
+		t.Error("A read-only scan shouldn't remove")
+	}
+	if _, err := ro.Status("a"); err != nil {
+		t.Errorf("a should still be there: %v", err)
+	}
+}
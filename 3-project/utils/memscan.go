@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+type MissingFile struct {
+	filename string
+}
+
+func (e *MissingFile) Error() string {
+	return fmt.Sprintf("file %q doesn't exist", e.filename)
+}
+
+// Unwrap makes errors.Is(err, fs.ErrNotExist) true
+func (e *MissingFile) Unwrap() error {
+	return fs.ErrNotExist
+}
+
+// MemScan is a CleanScan whose files only live in
+// memory, so the builder can run without touching
+// disk. Time is virtual: it starts at a given time
+// and each build moves it forward by a tick, which
+// is the time of the built file. Its state is owned
+// by a single goroutine, so it can be used by every
+// worker at once. Close stops it.
+type MemScan struct {
+	reqs chan func(*memFiles)
+}
+
+// memFiles is the state of a MemScan
+type memFiles struct {
+	times  map[string]time.Time // Of the existing files
+	builds map[string]int
+	now    time.Time
+	tick   time.Duration
+}
+
+// MemSnapshot is the state of the files
+// and the clock of a MemScan at some point
+type MemSnapshot struct {
+	times map[string]time.Time
+	now   time.Time
+}
+
+// NewMemScan returns a scan without files
+// whose clock starts at start
+func NewMemScan(start time.Time, tick time.Duration) *MemScan {
+	m := &MemScan{reqs: make(chan func(*memFiles))}
+	go m.own(&memFiles{
+		times:  make(map[string]time.Time),
+		builds: make(map[string]int),
+		now:    start,
+		tick:   tick,
+	})
+	return m
+}
+
+// own runs the requests, one at a time
+func (m *MemScan) own(files *memFiles) {
+	for req := range m.reqs {
+		req(files)
+	}
+}
+
+// do runs req on the state and waits for it
+func (m *MemScan) do(req func(*memFiles)) {
+	done := make(chan struct{})
+	m.reqs <- func(files *memFiles) {
+		req(files)
+		close(done)
+	}
+	<-done
+}
+
+// Close stops the scan, which can't be used afterwards
+func (m *MemScan) Close() {
+	close(m.reqs)
+}
+
+func (m *MemScan) Status(filename string) (t time.Time, err error) {
+	m.do(func(files *memFiles) {
+		var ok bool
+		if t, ok = files.times[filename]; !ok {
+			err = &MissingFile{filename: filename}
+		}
+	})
+	return
+}
+
+// Build moves the clock forward and
+// gives its time to the file
+func (m *MemScan) Build(filename string) (t time.Time, err error) {
+	m.do(func(files *memFiles) {
+		files.now = files.now.Add(files.tick)
+		files.times[filename] = files.now
+		files.builds[filename]++
+		t = files.now
+	})
+	return
+}
+
+func (m *MemScan) Remove(filename string) (err error) {
+	m.do(func(files *memFiles) {
+		if _, ok := files.times[filename]; !ok {
+			err = &MissingFile{filename: filename}
+			return
+		}
+		delete(files.times, filename)
+	})
+	return
+}
+
+// Set creates the file, or changes its time,
+// without counting it as a build
+func (m *MemScan) Set(filename string, t time.Time) {
+	m.do(func(files *memFiles) {
+		files.times[filename] = t
+	})
+}
+
+// Now returns the time of the virtual clock
+func (m *MemScan) Now() (t time.Time) {
+	m.do(func(files *memFiles) {
+		t = files.now
+	})
+	return
+}
+
+// Builds returns how many times the file was built
+func (m *MemScan) Builds(filename string) (n int) {
+	m.do(func(files *memFiles) {
+		n = files.builds[filename]
+	})
+	return
+}
+
+// Snapshot returns the current files and clock
+func (m *MemScan) Snapshot() *MemSnapshot {
+	s := &MemSnapshot{times: make(map[string]time.Time)}
+	m.do(func(files *memFiles) {
+		for f, t := range files.times {
+			s.times[f] = t
+		}
+		s.now = files.now
+	})
+	return s
+}
+
+// Restore brings back the files and the clock of the
+// snapshot. Build counts aren't part of it, they keep
+// growing.
+func (m *MemScan) Restore(s *MemSnapshot) {
+	m.do(func(files *memFiles) {
+		files.times = make(map[string]time.Time, len(s.times))
+		for f, t := range s.times {
+			files.times[f] = t
+		}
+		files.now = s.now
+	})
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestMemScan(t *testing.T) {
+	m := NewMemScan(start, time.Minute)
+	defer m.Close()
+
+	if _, err := m.Status("a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Missing file should be fs.ErrNotExist. got=%v", err)
+	}
+
+	m.Set("a", start.Add(-time.Hour))
+	if tm, err := m.Status("a"); err != nil || !tm.Equal(start.Add(-time.Hour)) {
+		t.Errorf("Wrong status of a. got=%v, %v", tm, err)
+	}
+	if m.Builds("a") != 0 {
+		t.Error("Setting a file isn't a build")
+	}
+
+	t1, _ := m.Build("b")
+	t2, _ := m.Build("b")
+	if !t1.Equal(start.Add(time.Minute)) || !t2.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Each build should take a tick. got=%v, %v", t1, t2)
+	}
+	if tm, _ := m.Status("b"); !tm.Equal(t2) {
+		t.Errorf("Status should be the last build time. got=%v", tm)
+	}
+	if n := m.Builds("b"); n != 2 {
+		t.Errorf("Expecting 2 builds of b. got=%d", n)
+	}
+	if !m.Now().Equal(t2) {
+		t.Errorf("Clock should be at the last build. got=%v", m.Now())
+	}
+
+	if err := m.Remove("b"); err != nil {
+		t.Errorf("b was there, should not have errored: %v", err)
+	}
+	if _, err := m.Status("b"); err == nil {
+		t.Error("b was removed, status should error")
+	}
+	if err := m.Remove("b"); err == nil {
+		t.Error("b was not there, should error")
+	}
+}
+
+func TestMemScanSnapshot(t *testing.T) {
+	m := NewMemScan(start, time.Second)
+	defer m.Close()
+
+	m.Set("a", start)
+	snap := m.Snapshot()
+
+	m.Build("a")
+	m.Build("b")
+	m.Remove("a")
+
+	m.Restore(snap)
+	if tm, err := m.Status("a"); err != nil || !tm.Equal(start) {
+		t.Errorf("a should be back. got=%v, %v", tm, err)
+	}
+	if _, err := m.Status("b"); err == nil {
+		t.Error("b didn't exist in the snapshot")
+	}
+	if !m.Now().Equal(start) {
+		t.Errorf("Clock should be back. got=%v", m.Now())
+	}
+	if m.Builds("a") != 1 || m.Builds("b") != 1 {
+		t.Error("Build counts aren't restored")
+	}
+
+	// Restoring twice gives the same files
+	m.Build("c")
+	m.Restore(snap)
+	if _, err := m.Status("c"); err == nil {
+		t.Error("c didn't exist in the snapshot")
+	}
+}
+
+func TestMemScanConcurrent(t *testing.T) {
+	m := NewMemScan(start, time.Millisecond)
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				m.Build("a")
+				m.Status("a")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := m.Builds("a"); n != 1000 {
+		t.Errorf("Expecting 1000 builds. got=%d", n)
+	}
+	if !m.Now().Equal(start.Add(1000 * time.Millisecond)) {
+		t.Errorf("Every build should move the clock. got=%v", m.Now())
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type FileScan struct {
 	basePath string
+	lock     *held // Nil if read-only
 }
 
 type InvalidDir struct {
@@ -21,25 +23,87 @@ func (e *InvalidDir) Error() string {
 	return fmt.Sprintf("path %q isn't a directory", e.path)
 }
 
+// ReadOnly is the error of a change
+// through a read-only FileScan
+type ReadOnly struct {
+	path string
+}
+
+func (e *ReadOnly) Error() string {
+	return fmt.Sprintf("%q can't be changed by a read-only scan", e.path)
+}
+
 type Scan interface {
 	Status(string) (time.Time, error)
 	Build(string) (time.Time, error)
 }
 
+// CleanScan is a Scan whose built
+// objects can also be removed
+type CleanScan interface {
+	Scan
+	Remove(string) error
+}
+
+// DryRun is a Scan that never builds. Objects are
+// "built" at the current time instead, so their
+// dependants are rebuilt as well.
+type DryRun struct {
+	Scan
+}
+
+func (d DryRun) Build(string) (time.Time, error) {
+	return time.Now(), nil
+}
+
 // NewFileScan returns a file scan given
-// a base path. Returns an error if it couldn't
-// validate the path or it doesn't point to a dir
+// a base path, taking its lock file. Returns an
+// error if it couldn't validate the path, it
+// doesn't point to a dir or it's Locked. Close
+// releases the lock.
 func NewFileScan(path string) (*FileScan, error) {
-	// Validates path
-	info, err := os.Stat(path)
+	if err := validDir(path); err != nil {
+		return nil, err
+	}
+
+	l, err := lock(lockPath(path))
 	if err != nil {
 		return nil, err
 	}
+
+	return &FileScan{basePath: path, lock: l}, nil
+}
+
+// NewReadOnlyScan returns a file scan given a base
+// path that only tells the status of the files, so
+// it doesn't take the lock: the commands that only
+// read can run along with a build. Its Build and
+// Remove fail with ReadOnly.
+func NewReadOnlyScan(path string) (*FileScan, error) {
+	if err := validDir(path); err != nil {
+		return nil, err
+	}
+	return &FileScan{basePath: path}, nil
+}
+
+// validDir checks that the path points to a dir
+func validDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
 	if !info.IsDir() {
-		return nil, &InvalidDir{path: path}
+		return &InvalidDir{path: path}
 	}
+	return nil
+}
 
-	return &FileScan{basePath: path}, nil
+// Close releases the lock of the base path, if any
+func (fscan *FileScan) Close() error {
+	if fscan.lock == nil {
+		return nil
+	}
+	return fscan.lock.release()
 }
 
 // join appends the base dir to path
@@ -67,11 +131,25 @@ func (fscan *FileScan) Status(filename string) (time.Time, error) {
 	return fs.ModTime(), nil
 }
 
+// Remove deletes the object file given by path.
+func (fscan *FileScan) Remove(filename string) error {
+	if fscan.lock == nil {
+		return &ReadOnly{path: fscan.join(filename)}
+	}
+	return os.Remove(fscan.join(filename))
+}
+
 // Build Fake builds the object file and returns its modification time.
+// The object is written to a temp file next to it, which is renamed
+// once complete, so an interrupted build never leaves a half written
+// object behind, only a partial output (see Partials).
 func (fscan *FileScan) Build(filename string) (time.Time, error) {
-	filename = fscan.join(filename)
+	path := fscan.join(filename)
+	if fscan.lock == nil {
+		return time.Time{}, &ReadOnly{path: path}
+	}
 
-	f, err := os.Open(filename)
+	f, err := os.Open(path)
 	var n int
 	if err == nil { // File existed, read n
 		scanner := bufio.NewScanner(f)
@@ -82,19 +160,65 @@ func (fscan *FileScan) Build(filename string) (time.Time, error) {
 		f.Close()
 	}
 
-	f, err = os.Create(filename)
-	defer f.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+partialMark+"*")
 	if err != nil {
 		return time.Time{}, err
 	}
-	_, err = f.WriteString(strconv.Itoa(n) + " times built.\n")
+	_, err = tmp.WriteString(strconv.Itoa(n) + " times built.\n")
+	if err == nil {
+		err = tmp.Chmod(0644) // Temp files are private
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
 	if err != nil {
+		os.Remove(tmp.Name())
 		return time.Time{}, err
 	}
 
-	fs, _ := f.Stat()
-	t := fs.ModTime()
+	return fscan.Status(filename)
+}
+
+// partialMark is in the name of the temp files of the
+// builds, e.g. ".main.o.tmp-123" while building main.o
+const partialMark = ".tmp-"
+
+// Partial is an output left by an interrupted build
+type Partial struct {
+	Target string // What was being built
+	Path   string
+}
+
+// PartialScan is a Scan whose interrupted
+// builds may leave partial outputs behind
+type PartialScan interface {
+	Scan
+	Partials() ([]*Partial, error)
+	RemovePartial(*Partial) error
+}
 
-	return t, nil
+// Partials returns the temp files of the
+// builds that never got to rename them
+func (fscan *FileScan) Partials() ([]*Partial, error) {
+	paths, err := filepath.Glob(fscan.join(".*" + partialMark + "*"))
+	if err != nil {
+		return nil, err
+	}
+	var res []*Partial
+	for _, path := range paths {
+		name := strings.TrimPrefix(filepath.Base(path), ".")
+		target := name[:strings.LastIndex(name, partialMark)]
+		res = append(res, &Partial{Target: target, Path: path})
+	}
+	return res, nil
+}
 
+func (fscan *FileScan) RemovePartial(p *Partial) error {
+	if fscan.lock == nil {
+		return &ReadOnly{path: p.Path}
+	}
+	return os.Remove(p.Path)
 }
@@ -3,13 +3,15 @@ package utils
 import (
 	"bufio"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 )
 
 var fileScan *FileScan
 
-func init() {
+// TestMain releases the lock of the scan shared by the tests
+func TestMain(m *testing.M) {
 	path := os.Getenv("UTILS_TEST_PATH")
 	if path == "" {
 		path = "./"
@@ -18,6 +20,9 @@ func init() {
 	if fileScan, err = NewFileScan(path); err != nil {
 		panic(err)
 	}
+	code := m.Run()
+	fileScan.Close()
+	os.Exit(code)
 }
 
 func TestFreshBuild(t *testing.T) {
@@ -89,3 +94,84 @@ func TestStatusExisting(t *testing.T) {
 		t.Error("Times should match.")
 	}
 }
+
+func TestRemove(t *testing.T) {
+	s := "baz"
+	f := fileScan.create(s)
+	f.Close()
+	if err := fileScan.Remove(s); err != nil {
+		t.Error("File was there, should not have errored.")
+	}
+	if _, err := fileScan.Status(s); err == nil {
+		t.Error("File was removed, status should error.")
+	}
+	if err := fileScan.Remove(s); err == nil {
+		t.Error("File was not there, should error.")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	s := "qux"
+	fileScan.remove(s)
+	dryRun := DryRun{fileScan}
+	if _, err := dryRun.Build(s); err != nil {
+		t.Error("Dry run should not error.")
+	}
+	if _, err := dryRun.Status(s); err == nil {
+		t.Error("Dry run should not create the file.")
+	}
+}
+
+func TestBuildAtomic(t *testing.T) {
+	dir := t.TempDir()
+	scan, _ := NewFileScan(dir)
+
+	for i := 0; i < 2; i++ {
+		if _, err := scan.Build("main.o"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "main.o"))
+	if string(b) != "1 times built.\n" {
+		t.Errorf("Wrong object. got=%q", b)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 { // With the lock
+		t.Errorf("Builds shouldn't leave temp files behind. got=%d files", len(entries))
+	}
+	if partials, _ := scan.Partials(); len(partials) != 0 {
+		t.Errorf("Expecting no partial outputs. got=%v", partials)
+	}
+}
+
+func TestPartials(t *testing.T) {
+	dir := t.TempDir()
+	scan, _ := NewFileScan(dir)
+	for _, f := range []string{".main.o.tmp-123", "main.o", ".hidden", ".a.tmp-b.tmp-4"} {
+		os.WriteFile(filepath.Join(dir, f), nil, 0644)
+	}
+
+	partials, err := scan.Partials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, p := range partials {
+		got[filepath.Base(p.Path)] = p.Target
+	}
+	if len(got) != 2 || got[".main.o.tmp-123"] != "main.o" || got[".a.tmp-b.tmp-4"] != "a.tmp-b" {
+		t.Errorf("Wrong partial outputs. got=%v", got)
+	}
+
+	for _, p := range partials {
+		if err := scan.RemovePartial(p); err != nil {
+			t.Error(err)
+		}
+	}
+	if partials, _ := scan.Partials(); len(partials) != 0 {
+		t.Errorf("Partial outputs should be removed. got=%v", partials)
+	}
+	if _, err := scan.Status("main.o"); err != nil {
+		t.Error("main.o isn't a partial output")
+	}
+}
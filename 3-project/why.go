@@ -0,0 +1,80 @@
+package main
+
+import (
+	"cpl_go_proj22/builder"
+	"cpl_go_proj22/parser"
+	"cpl_go_proj22/utils"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// why explains the rebuild of a target, either on
+// the last build or on a dry run of a new one
+func why(args []string) {
+	flags := flag.NewFlagSet("why", flag.ExitOnError)
+	path := flags.String("d", "", "Files location, (current directory by default)")
+	last := flags.Bool("last", false, "Explain the last build instead of a dry run")
+	hist := addHistoryFlag(flags)
+	s3 := addS3Flags(flags)
+	flags.Parse(args)
+	args = flags.Args()
+	// The last build doesn't need the dependency file
+	if *last && len(args) != 1 || !*last && len(args) != 2 {
+		fmt.Println("Usage: project why [-d | -s3 url [-s3-endpoint] [-s3-region]] <location> <target>")
+		fmt.Println("       project why [-d] -last [-history] <target>")
+		os.Exit(0)
+	}
+	if err := s3.check(flags); err != nil {
+		log.Fatal(err.Error())
+	}
+	target := args[len(args)-1]
+
+	var events []*builder.Event
+	if *last {
+		hp := s3.historyPath(*hist, *path)
+		if hp == "" {
+			log.Fatal("No history is kept, -history tells where it is")
+		}
+		events = loadHistory(hp).LastBuild
+	} else {
+		dFile, err := parser.ParseFile(args[0])
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		// A dry run only reads, so a build can be running
+		var scan utils.Scan
+		if s3.set() {
+			scan, err = s3.scan()
+		} else {
+			scan, err = utils.NewReadOnlyScan(*path)
+		}
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		evCh := make(chan *builder.Event, 64)
+		ch := builder.MakeController(
+			dFile, utils.DryRun{Scan: scan}, builder.WithEvents(evCh),
+		)
+		for ev := range evCh {
+			events = append(events, ev)
+		}
+		<-ch
+	}
+
+	chain, err := builder.Explain(events, target)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for i, step := range chain {
+		if i == 0 {
+			fmt.Println(step)
+		} else {
+			fmt.Println("-> " + step)
+		}
+	}
+}